@@ -0,0 +1,242 @@
+// Package boltstore is a coa.KeyValueStore backed by a bbolt database.
+//
+// Charts of accounts go to the "charts-of-accounts" bucket, and the keys of
+// each chart's accounts and history go to a bucket named after the chart,
+// nested in the "accounts" and "history" buckets. Any other key goes to the
+// "coa" bucket. Keys are stored whole inside their bucket, so a scan within
+// a chart is a single cursor walk.
+package boltstore
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/go-accounting/coa"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store implements coa.TransactionalKeyValueStore and
+// coa.ScannableKeyValueStore.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database file at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return New(db), nil
+}
+
+func New(db *bolt.DB) *Store {
+	return &Store{db}
+}
+
+func (s *Store) DB() *bolt.DB {
+	return s.db
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Get(key []byte) (value []byte, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value, err = txStore{tx}.Get(key)
+		return err
+	})
+	return
+}
+
+func (s *Store) Put(key []byte, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return txStore{tx}.Put(key, value)
+	})
+}
+
+func (s *Store) Delete(key []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return txStore{tx}.Delete(key)
+	})
+}
+
+func (s *Store) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	var kvs [][2][]byte
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		kvs, err = txStore{tx}.collect(prefix)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return each(kvs, fn)
+}
+
+// Update runs fn in a single bbolt read-write transaction, committed only if
+// fn returns nil.
+func (s *Store) Update(fn func(tx coa.KeyValueStore) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(txStore{tx})
+	})
+}
+
+type txStore struct {
+	tx *bolt.Tx
+}
+
+func (s txStore) Get(key []byte) ([]byte, error) {
+	b := s.bucket(key, false)
+	if b == nil {
+		return nil, nil
+	}
+	// values are only valid while the transaction is open
+	return clone(b.Get(key)), nil
+}
+
+func (s txStore) Put(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return bolt.ErrKeyRequired
+	}
+	b, err := s.createBucket(key)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, clone(value))
+}
+
+func (s txStore) Delete(key []byte) error {
+	b := s.bucket(key, false)
+	if b == nil {
+		return nil
+	}
+	return b.Delete(key)
+}
+
+func (s txStore) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	kvs, err := s.collect(prefix)
+	if err != nil {
+		return err
+	}
+	return each(kvs, fn)
+}
+
+// collect copies the pairs matching prefix out of the buckets before fn is
+// called, so fn is free to write to the store.
+func (s txStore) collect(prefix []byte) ([][2][]byte, error) {
+	var kvs [][2][]byte
+	add := func(b *bolt.Bucket) {
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if v == nil && b.Bucket(k) != nil {
+				continue
+			}
+			kvs = append(kvs, [2][]byte{clone(k), clone(v)})
+		}
+	}
+	if b := s.bucket(prefix, true); b != nil {
+		add(b)
+		return kvs, nil
+	}
+	// the prefix spans several buckets
+	for _, name := range []string{chartsBucket, defaultBucket} {
+		if b := s.tx.Bucket([]byte(name)); b != nil {
+			add(b)
+		}
+	}
+	for _, name := range perChartBuckets {
+		parent := s.tx.Bucket([]byte(name))
+		if parent == nil {
+			continue
+		}
+		parent.ForEach(func(k, v []byte) error {
+			if v == nil {
+				add(parent.Bucket(k))
+			}
+			return nil
+		})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i][0], kvs[j][0]) < 0 })
+	return kvs, nil
+}
+
+const (
+	chartsBucket  = "charts-of-accounts"
+	defaultBucket = "coa"
+)
+
+var perChartBuckets = []string{"accounts", "history"}
+
+// route returns the bucket path of key. A prefix is routed only when every
+// key starting with it lives in the same bucket.
+func route(key []byte, prefix bool) []string {
+	s := string(key)
+	for _, name := range perChartBuckets {
+		if !strings.HasPrefix(s, name+"/") {
+			continue
+		}
+		coaid := s[len(name)+1:]
+		if i := strings.IndexByte(coaid, '/'); i >= 0 {
+			coaid = coaid[:i]
+		} else if prefix {
+			return nil
+		}
+		if coaid != "" {
+			return []string{name, coaid}
+		}
+	}
+	if s == chartsBucket || strings.HasPrefix(s, chartsBucket+"/") {
+		return []string{chartsBucket}
+	}
+	if prefix {
+		return nil
+	}
+	return []string{defaultBucket}
+}
+
+func (s txStore) bucket(key []byte, prefix bool) *bolt.Bucket {
+	path := route(key, prefix)
+	if path == nil {
+		return nil
+	}
+	b := s.tx.Bucket([]byte(path[0]))
+	for _, name := range path[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket([]byte(name))
+	}
+	return b
+}
+
+func (s txStore) createBucket(key []byte) (*bolt.Bucket, error) {
+	path := route(key, false)
+	b, err := s.tx.CreateBucketIfNotExists([]byte(path[0]))
+	for _, name := range path[1:] {
+		if err != nil {
+			return nil, err
+		}
+		b, err = b.CreateBucketIfNotExists([]byte(name))
+	}
+	return b, err
+}
+
+func each(kvs [][2][]byte, fn func(key []byte, value []byte) error) error {
+	for _, kv := range kvs {
+		if err := fn(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	result := make([]byte, len(b))
+	copy(result, b)
+	return result
+}
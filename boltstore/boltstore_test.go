@@ -0,0 +1,70 @@
+package boltstore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-accounting/coa"
+	"github.com/go-accounting/coa/storetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func open(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "coa.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) coa.KeyValueStore { return open(t) })
+}
+
+func TestBucketPerChart(t *testing.T) {
+	s := open(t)
+	r := coa.NewCoaRepository(s, coa.WithLayout(coa.PerAccountLayout))
+	c, err := r.SaveChartOfAccounts(&coa.ChartOfAccounts{Name: "coa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SaveAccount(c.Id, &coa.Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.DB().View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("charts-of-accounts")).Get([]byte("charts-of-accounts/"+c.Id)) == nil {
+			t.Error("Expected the chart in the charts-of-accounts bucket")
+		}
+		b := tx.Bucket([]byte("accounts")).Bucket([]byte(c.Id))
+		if b == nil || b.Get([]byte("accounts/"+c.Id+"/ids")) == nil {
+			t.Error("Expected the accounts in the chart's bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	for key, expected := range map[string]string{
+		"charts-of-accounts":     "charts-of-accounts",
+		"charts-of-accounts/c1":  "charts-of-accounts",
+		"accounts/c1":            "accounts c1",
+		"accounts/c1/number/11":  "accounts c1",
+		"history/c1/a1/00000001": "history c1",
+		"accounts/":              "coa",
+		"other":                  "coa",
+	} {
+		if actual := strings.Join(route([]byte(key), false), " "); actual != expected {
+			t.Errorf("Expected %v for %v but was %v", expected, key, actual)
+		}
+	}
+	for _, prefix := range []string{"accounts/c1", "accounts/", "charts", ""} {
+		if path := route([]byte(prefix), true); path != nil {
+			t.Errorf("Expected prefix %v to span buckets but was %v", prefix, path)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package coa
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithCache keeps the decoded accounts of recently used charts stored in
+// BlobLayout in memory, up to maxAccounts accounts in all, so reads don't
+// decode the chart's blob again. Writes made through the repository update
+// the cache once committed; writes made to the store by anything else are
+// not seen.
+func WithCache(maxAccounts int) Option {
+	return func(r *CoaRepository) {
+		r.cache = newAccountsCache(maxAccounts)
+	}
+}
+
+// accountsCache is a LRU cache of charts' accounts. Cached accounts are
+// shared and never modified.
+type accountsCache struct {
+	mu     sync.Mutex
+	max    int
+	size   int
+	lru    *list.List
+	charts map[string]*list.Element
+}
+
+type cacheEntry struct {
+	coaid    string
+	accounts Accounts
+}
+
+func newAccountsCache(maxAccounts int) *accountsCache {
+	return &accountsCache{max: maxAccounts, lru: list.New(), charts: map[string]*list.Element{}}
+}
+
+func (c *accountsCache) get(coaid string) (Accounts, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.charts[coaid]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).accounts, true
+}
+
+func (c *accountsCache) add(coaid string, accounts Accounts) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(coaid)
+	if len(accounts) > c.max {
+		return
+	}
+	c.charts[coaid] = c.lru.PushFront(&cacheEntry{coaid, accounts})
+	c.size += len(accounts)
+	for c.size > c.max {
+		c.removeLocked(c.lru.Back().Value.(*cacheEntry).coaid)
+	}
+}
+
+func (c *accountsCache) remove(coaid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(coaid)
+}
+
+func (c *accountsCache) removeLocked(coaid string) {
+	if e, ok := c.charts[coaid]; ok {
+		c.lru.Remove(e)
+		delete(c.charts, coaid)
+		c.size -= len(e.Value.(*cacheEntry).accounts)
+	}
+}
+
+// cachedAccounts returns the chart's accounts as last written by the
+// running transaction or as found in the cache.
+func (r *CoaRepository) cachedAccounts(coaid string) (Accounts, bool) {
+	if accounts, ok := r.written[coaid]; ok {
+		return accounts, true
+	}
+	if r.cache == nil {
+		return nil, false
+	}
+	return r.cache.get(coaid)
+}
+
+func (r *CoaRepository) cacheWritten(coaid string, accounts Accounts) {
+	if r.cache == nil {
+		return
+	}
+	if r.written != nil {
+		r.written[coaid] = accounts
+	} else {
+		r.cache.add(coaid, accounts)
+	}
+}
+
+func (a *Account) clone() *Account {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	c.Tags = append(Tags(nil), a.Tags...)
+	// as decoded accounts have no monotonic clock reading
+	c.AsOf, c.Created, c.Removed = a.AsOf.Round(0), a.Created.Round(0), a.Removed.Round(0)
+	return &c
+}
+
+func (aa Accounts) clone() Accounts {
+	if aa == nil {
+		return nil
+	}
+	result := make(Accounts, len(aa))
+	for i, a := range aa {
+		result[i] = a.clone()
+	}
+	return result
+}
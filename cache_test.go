@@ -0,0 +1,105 @@
+package coa
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// countingStore counts the reads of accounts blobs.
+type countingStore struct {
+	store
+	gets int
+}
+
+func (s *countingStore) Get(key []byte) ([]byte, error) {
+	if strings.HasPrefix(string(key), "accounts/") {
+		s.gets++
+	}
+	return s.store.Get(key)
+}
+
+func TestCache(t *testing.T) {
+	s := &countingStore{store: store{}}
+	r := NewCoaRepository(s, WithCache(100))
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	s.gets = 0
+	a11, err := r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a, err := r.GetAccount(coa.Id, a1.Id)
+	check(t, err)
+	if _, err := r.AllAccounts(coa.Id); err != nil {
+		t.Fatal(err)
+	}
+	// the journal reads the blob once before overwriting it
+	if s.gets != 1 {
+		t.Errorf("Expected 1 read but was %v", s.gets)
+	}
+	if !a.Tags.Contains("summary") {
+		t.Errorf("Expected a1 to be summary but tags were %v", a.Tags)
+	}
+	a.Name = "changed"
+	a.Tags[0] = "changed"
+	a11.Name = "changed"
+	accounts, err := r.AllAccounts(coa.Id)
+	check(t, err)
+	if accounts[0].Name != "a1" || accounts[0].Tags[0] == "changed" || accounts[1].Name != "a11" {
+		t.Errorf("Expected the cached accounts to be left untouched but was %v %v", accounts[0], accounts[1])
+	}
+	other := NewCoaRepository(s.store)
+	stored, err := other.AllAccounts(coa.Id)
+	check(t, err)
+	if fmt.Sprint(stored) != fmt.Sprint(accounts) {
+		t.Errorf("Expected the store to hold %v but was %v", accounts, stored)
+	}
+}
+
+func TestCacheSizeBound(t *testing.T) {
+	c := newAccountsCache(3)
+	c.add("c1", Accounts{{Id: "a1"}, {Id: "a2"}})
+	c.add("c2", Accounts{{Id: "a3"}})
+	if _, ok := c.get("c1"); !ok {
+		t.Error("Expected c1 to be cached")
+	}
+	c.add("c3", Accounts{{Id: "a4"}})
+	if _, ok := c.get("c2"); ok {
+		t.Error("Expected the least recently used c2 to be evicted")
+	}
+	if _, ok := c.get("c1"); !ok {
+		t.Error("Expected c1 to be cached")
+	}
+	c.add("c4", Accounts{{Id: "a5"}, {Id: "a6"}, {Id: "a7"}, {Id: "a8"}})
+	if _, ok := c.get("c4"); ok {
+		t.Error("Expected a chart bigger than the cache not to be cached")
+	}
+	c.add("c1", Accounts{{Id: "a1"}})
+	if c.size != 2 || c.lru.Len() != 2 {
+		t.Errorf("Expected 2 accounts in 2 charts but was %v in %v", c.size, c.lru.Len())
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	const n = 1000
+	for name, options := range map[string][]Option{"Uncached": nil, "Cached": {WithCache(n * 2)}} {
+		r, coaid, accounts := benchmarkRepository(b, n, options...)
+		b.Run(name+"/GetAccount", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := r.GetAccount(coaid, accounts[i%n].Id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/UpdateAccount", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				a := accounts[i%n]
+				a.Name = fmt.Sprint("account", i)
+				if _, err := r.SaveAccount(coaid, a); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -3,13 +3,13 @@
 package coa
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
-	"github.com/tinylib/msgp/msgp"
 )
 
 type ChartOfAccounts struct {
@@ -20,6 +20,12 @@ type ChartOfAccounts struct {
 	AsOf                    time.Time `json:"timestamp"`
 	Created                 time.Time `json:"-"`
 	Removed                 time.Time `json:"-"`
+	Version                 int       `json:"version"`
+	Mask                    string    `json:"mask"`
+	NaturalOrdering         bool      `json:"naturalOrdering"`
+	MaxDepth                int       `json:"maxDepth"`
+	MinPostingLevel         int       `json:"minPostingLevel"`
+	MinTopLevelGroups       int       `json:"minTopLevelGroups"`
 }
 
 type Account struct {
@@ -32,22 +38,24 @@ type Account struct {
 	AsOf    time.Time `json:"timestamp"`
 	Created time.Time `json:"-"`
 	Removed time.Time `json:"-"`
+	Version int       `json:"version"`
 }
 
 type ChartsOfAccounts []*ChartOfAccounts
 type Accounts []*Account
 type Tags []string
+type idList []string
 
 var inheritedProperties = map[string]string{
-	"balanceSheet":    "financial statement",
-	"incomeStatement": "financial statement",
-	"operating":       "income statement attribute",
-	"deduction":       "income statement attribute",
-	"salesTax":        "income statement attribute",
-	"cost":            "income statement attribute",
-	"nonOperatingTax": "income statement attribute",
-	"incomeTax":       "income statement attribute",
-	"dividends":       "income statement attribute",
+	"balanceSheet":    "financialStatement",
+	"incomeStatement": "financialStatement",
+	"operating":       "incomeStatementAttribute",
+	"deduction":       "incomeStatementAttribute",
+	"salesTax":        "incomeStatementAttribute",
+	"cost":            "incomeStatementAttribute",
+	"nonOperatingTax": "incomeStatementAttribute",
+	"incomeTax":       "incomeStatementAttribute",
+	"dividends":       "incomeStatementAttribute",
 }
 
 var nonInheritedProperties = map[string]string{
@@ -63,24 +71,82 @@ type KeyValueStore interface {
 }
 
 type CoaRepository struct {
-	store KeyValueStore
+	store   KeyValueStore
+	catalog Catalog
+	locks   *locks
+	layout  accountLayout
+	cache   *accountsCache
+	// written holds the charts saved by the running transaction, which
+	// reach the cache only once it commits.
+	written       map[string]Accounts
+	migrations    *Migrations
+	rewriteOnRead bool
+	codec         Codec
+	autoNumber    bool
+	history       bool
 }
 
-func NewCoaRepository(store KeyValueStore) *CoaRepository {
-	return &CoaRepository{store}
+type Option func(*CoaRepository)
+
+func WithCatalog(c Catalog) Option {
+	return func(r *CoaRepository) { r.catalog = c }
+}
+
+func WithLocale(locale string) Option {
+	return WithCatalog(CatalogFor(locale))
+}
+
+func NewCoaRepository(store KeyValueStore, options ...Option) *CoaRepository {
+	r := &CoaRepository{store: store, catalog: English, locks: newLocks(), layout: blobLayout{}, migrations: DefaultMigrations, codec: Msgp}
+	for _, option := range options {
+		option(r)
+	}
+	return r
 }
 
 func (r *CoaRepository) AllChartsOfAccounts() (ChartsOfAccounts, error) {
-	var result ChartsOfAccounts
-	err := r.get("charts-of-accounts", &result)
+	defer r.locks.readCharts()()
+	return r.allChartsOfAccounts(false)
+}
+
+func (r *CoaRepository) AllChartsOfAccountsIncludingRemoved() (ChartsOfAccounts, error) {
+	defer r.locks.readCharts()()
+	return r.allChartsOfAccounts(true)
+}
+
+func (r *CoaRepository) allChartsOfAccounts(includeRemoved bool) (ChartsOfAccounts, error) {
+	var coas ChartsOfAccounts
+	err := r.get("charts-of-accounts", &coas)
 	if err != nil {
 		return nil, err
 	}
+	if r.scannable() {
+		coas, err = r.scanChartsOfAccounts(coas)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var result ChartsOfAccounts
+	for _, coa := range coas {
+		if includeRemoved || coa.Removed.IsZero() {
+			result = append(result, coa)
+		}
+	}
 	return result, nil
 }
 
 func (r *CoaRepository) GetChartOfAccounts(coaid string) (*ChartOfAccounts, error) {
-	coas, err := r.AllChartsOfAccounts()
+	defer r.locks.readCharts()()
+	return r.getChartOfAccounts(coaid, false)
+}
+
+func (r *CoaRepository) GetChartOfAccountsIncludingRemoved(coaid string) (*ChartOfAccounts, error) {
+	defer r.locks.readCharts()()
+	return r.getChartOfAccounts(coaid, true)
+}
+
+func (r *CoaRepository) getChartOfAccounts(coaid string, includeRemoved bool) (*ChartOfAccounts, error) {
+	coas, err := r.allChartsOfAccounts(includeRemoved)
 	if err != nil {
 		return nil, err
 	}
@@ -94,12 +160,26 @@ func (r *CoaRepository) GetChartOfAccounts(coaid string) (*ChartOfAccounts, erro
 
 func (r *CoaRepository) SaveChartOfAccounts(coa *ChartOfAccounts) (*ChartOfAccounts, error) {
 	if coa == nil {
-		return nil, fmt.Errorf("Invalid argument: coa is nil")
+		return nil, ErrNilChartOfAccounts
+	}
+	defer r.locks.writeCharts()()
+	saved := *coa
+	err := r.atomically(func(r *CoaRepository) error {
+		_, err := r.saveChartOfAccounts(coa)
+		return err
+	})
+	if err != nil {
+		*coa = saved
+		return nil, err
 	}
-	if msg := coa.ValidationMessage(); msg != "" {
-		return nil, fmt.Errorf(msg)
+	return coa, nil
+}
+
+func (r *CoaRepository) saveChartOfAccounts(coa *ChartOfAccounts) (*ChartOfAccounts, error) {
+	if err := r.localize(coa.Validate()); err != nil {
+		return nil, err
 	}
-	coas, err := r.AllChartsOfAccounts()
+	coas, err := r.allChartsOfAccounts(true)
 	if err != nil {
 		return nil, err
 	}
@@ -107,55 +187,184 @@ func (r *CoaRepository) SaveChartOfAccounts(coa *ChartOfAccounts) (*ChartOfAccou
 	if coa.Id == "" {
 		coa.Id = uuid.NewV4().String()
 		coa.Created = time.Now()
+		coa.Removed = time.Time{}
+		coa.Version = 1
 		coas = append(coas, coa)
 	} else {
+		found := false
 		for i, eachcoa := range coas {
 			if eachcoa.Id == coa.Id {
+				found = true
+				if !eachcoa.Removed.IsZero() {
+					return nil, fmt.Errorf("%w: %v", ErrChartOfAccountsRemoved, coa.Id)
+				}
+				if eachcoa.Version != coa.Version {
+					return nil, conflict(coa.Id, coa.Version, eachcoa.Version)
+				}
+				coa.Version++
+				coa.Created, coa.Removed = eachcoa.Created, eachcoa.Removed
 				coas[i] = coa
 				break
 			}
 		}
+		if !found {
+			return nil, fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coa.Id)
+		}
 	}
-	sort.Slice(coas, func(i, j int) bool { return strings.Compare(coas[i].Name, coas[j].Name) < 0 })
-	err = r.put("charts-of-accounts", coas)
+	err = r.putChartsOfAccounts(coas, coa)
 	if err != nil {
 		return nil, err
 	}
 	return coa, nil
 }
 
+func (r *CoaRepository) RemoveChartOfAccounts(coaid string) error {
+	defer r.locks.writeCharts()()
+	return r.atomically(func(r *CoaRepository) error {
+		return r.setChartOfAccountsRemoved(coaid, true)
+	})
+}
+
+func (r *CoaRepository) RestoreChartOfAccounts(coaid string) error {
+	defer r.locks.writeCharts()()
+	return r.atomically(func(r *CoaRepository) error {
+		return r.setChartOfAccountsRemoved(coaid, false)
+	})
+}
+
+func (r *CoaRepository) setChartOfAccountsRemoved(coaid string, removed bool) error {
+	if coaid == "" {
+		return ErrEmptyCoaid
+	}
+	coas, err := r.allChartsOfAccounts(true)
+	if err != nil {
+		return err
+	}
+	for _, coa := range coas {
+		if coa.Id != coaid {
+			continue
+		}
+		if removed == !coa.Removed.IsZero() {
+			return nil
+		}
+		coa.AsOf = time.Now()
+		coa.Version++
+		if removed {
+			coa.Removed = coa.AsOf
+		} else {
+			coa.Removed = time.Time{}
+		}
+		return r.putChartsOfAccounts(coas, coa)
+	}
+	return fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coaid)
+}
+
+func (r *CoaRepository) putChartsOfAccounts(coas ChartsOfAccounts, changed *ChartOfAccounts) error {
+	if r.scannable() {
+		return r.putChartOfAccounts(coas, changed)
+	}
+	sort.Slice(coas, func(i, j int) bool { return strings.Compare(coas[i].Name, coas[j].Name) < 0 })
+	return r.put("charts-of-accounts", coas)
+}
+
+func (r *CoaRepository) checkChartOfAccounts(coaid string) error {
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return err
+	}
+	if coa == nil {
+		return fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coaid)
+	}
+	if !coa.Removed.IsZero() {
+		return fmt.Errorf("%w: %v", ErrChartOfAccountsRemoved, coaid)
+	}
+	return nil
+}
+
 func (r *CoaRepository) AllAccounts(coaid string) (Accounts, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.allAccounts(coaid, false)
+}
+
+func (r *CoaRepository) AllAccountsIncludingRemoved(coaid string) (Accounts, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.allAccounts(coaid, true)
+}
+
+func (r *CoaRepository) allAccounts(coaid string, includeRemoved bool) (Accounts, error) {
 	if coaid == "" {
-		return nil, fmt.Errorf("Invalid argument: coaid is empty")
+		return nil, ErrEmptyCoaid
 	}
-	var result Accounts
-	err := r.get("accounts/"+coaid, &result)
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return nil, err
+	}
+	return r.chartAccounts(coaid, coa, includeRemoved)
+}
+
+// chartAccounts is allAccounts for a chart already read, nil if missing.
+func (r *CoaRepository) chartAccounts(coaid string, coa *ChartOfAccounts, includeRemoved bool) (Accounts, error) {
+	accounts, err := r.layout.all(r, coaid)
 	if err != nil {
 		return nil, err
 	}
-	sort.Slice(result, func(i, j int) bool { return strings.Compare(result[i].Number, result[j].Number) < 0 })
+	var result Accounts
+	for _, a := range accounts {
+		if includeRemoved || a.Removed.IsZero() {
+			result = append(result, a)
+		}
+	}
+	coa.sortAccounts(result)
 	return result, nil
 }
 
 func (r *CoaRepository) GetAccount(coaid string, id string) (*Account, error) {
-	aa, err := r.AllAccounts(coaid)
+	defer r.locks.readAccounts(coaid)()
+	return r.getAccount(coaid, id, false)
+}
+
+func (r *CoaRepository) GetAccountIncludingRemoved(coaid string, id string) (*Account, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.getAccount(coaid, id, true)
+}
+
+func (r *CoaRepository) getAccount(coaid string, id string, includeRemoved bool) (*Account, error) {
+	if coaid == "" {
+		return nil, ErrEmptyCoaid
+	}
+	aa, err := r.layout.get(r, coaid, id)
 	if err != nil {
 		return nil, err
 	}
-	for _, a := range aa {
-		if a.Id == id {
-			return a, nil
-		}
+	if aa[0] == nil || !includeRemoved && !aa[0].Removed.IsZero() {
+		return nil, nil
 	}
-	return nil, nil
+	return aa[0], nil
 }
 
 func (r *CoaRepository) SaveAccount(coaid string, account *Account) (*Account, error) {
+	if account == nil {
+		return nil, ErrNilAccount
+	}
+	defer r.locks.writeAccounts(coaid, account.Tags.Contains("retainedEarnings"))()
+	saved := *account
+	err := r.atomically(func(r *CoaRepository) error {
+		_, err := r.saveAccount(coaid, account)
+		return err
+	})
+	if err != nil {
+		*account = saved
+		return nil, err
+	}
+	return account, nil
+}
+
+func (r *CoaRepository) saveAccount(coaid string, account *Account) (*Account, error) {
 	if coaid == "" {
-		return nil, fmt.Errorf("Invalid argument: coaid is empty")
+		return nil, ErrEmptyCoaid
 	}
-	if account == nil {
-		return nil, fmt.Errorf("Invalid argument: account is nil")
+	if err := r.checkChartOfAccounts(coaid); err != nil {
+		return nil, err
 	}
 	var tags []string
 	var retainedEarningsAccount bool
@@ -175,51 +384,57 @@ func (r *CoaRepository) SaveAccount(coaid string, account *Account) (*Account, e
 	account.Tags = tags
 	account.AsOf = time.Now()
 	if account.Id != "" {
-		old, err := r.GetAccount(coaid, account.Id)
+		old, err := r.getAccount(coaid, account.Id, false)
 		if err != nil {
 			return nil, err
 		}
+		if old == nil {
+			return nil, fmt.Errorf("%w: %v", ErrAccountNotFound, account.Id)
+		}
+		if old.Version != account.Version {
+			return nil, conflict(account.Id, account.Version, old.Version)
+		}
 		account.Number = old.Number
 		account.Parent = old.Parent
 		account.Created = old.Created
+		account.Removed = old.Removed
+	} else {
+		account.Removed = time.Time{}
 	}
-	if msg := account.ValidationMessage(coaid, r); msg != "" {
-		return nil, fmt.Errorf(msg)
+	if r.autoNumber && account.Id == "" && strings.TrimSpace(account.Number) == "" {
+		number, err := r.suggestNumber(coaid, account.Parent)
+		if err != nil && !errors.Is(err, ErrAccountNotFound) {
+			return nil, err
+		}
+		account.Number = number
 	}
-	var accounts Accounts
-	err := r.get("accounts/"+coaid, &accounts)
-	if err != nil {
+	if err := account.validate(coaid, r); err != nil {
 		return nil, err
 	}
 	if account.Id == "" {
 		account.Id = uuid.NewV4().String()
 		account.Created = time.Now()
-		accounts = append(accounts, account)
+		account.Version = 1
 	} else {
-		for i, a := range accounts {
-			if account.Id == a.Id {
-				accounts[i] = account
-				break
-			}
-		}
+		account.Version++
 	}
-	err = r.put("accounts/"+coaid, accounts)
+	err := r.saveAccounts(coaid, account)
 	if err != nil {
 		return nil, err
 	}
 	if retainedEarningsAccount {
-		coa, err := r.GetChartOfAccounts(coaid)
+		coa, err := r.getChartOfAccounts(coaid, false)
 		if err != nil {
 			return nil, err
 		}
 		coa.RetainedEarningsAccount = account.Id
-		_, err = r.SaveChartOfAccounts(coa)
+		_, err = r.saveChartOfAccounts(coa)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if account.Parent != "" {
-		parent, err := r.GetAccount(coaid, account.Parent)
+		parent, err := r.getAccount(coaid, account.Parent, false)
 		if err != nil {
 			return nil, err
 		}
@@ -234,7 +449,7 @@ func (r *CoaRepository) SaveAccount(coaid string, account *Account) (*Account, e
 			changed = true
 		}
 		if changed {
-			_, err := r.SaveAccount(coaid, parent)
+			_, err := r.saveAccount(coaid, parent)
 			if err != nil {
 				return nil, err
 			}
@@ -243,102 +458,286 @@ func (r *CoaRepository) SaveAccount(coaid string, account *Account) (*Account, e
 	return account, nil
 }
 
+func (r *CoaRepository) saveAccounts(coaid string, accounts ...*Account) error {
+	if err := r.layout.save(r, coaid, accounts...); err != nil {
+		return err
+	}
+	return r.recordHistory(coaid, accounts...)
+}
+
+// Indexes returns the position of each account, or -1 for missing accounts
+// and those lacking any of tags. Positions are in the order of AllAccounts
+// for charts with NaturalOrdering, and may shift as accounts are added or
+// renumbered. For other charts they are in creation order and never shift.
 func (r *CoaRepository) Indexes(coaid string, accountsIds []string, tags []string) ([]int, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.indexes(coaid, accountsIds, tags, false)
+}
+
+func (r *CoaRepository) IndexesIncludingRemoved(coaid string, accountsIds []string, tags []string) ([]int, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.indexes(coaid, accountsIds, tags, true)
+}
+
+func (r *CoaRepository) indexes(coaid string, accountsIds []string, tags []string, includeRemoved bool) ([]int, error) {
 	if coaid == "" {
-		return nil, fmt.Errorf("Invalid argument: coaid is empty")
+		return nil, ErrEmptyCoaid
 	}
-	var accounts Accounts
-	err := r.get("accounts/"+coaid, &accounts)
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if coa != nil && coa.NaturalOrdering {
+		accounts, err := r.chartAccounts(coaid, coa, includeRemoved)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accounts {
+			ids = append(ids, a.Id)
+		}
+	} else {
+		ids, err = r.layout.ids(r, coaid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+	accounts, err := r.layout.get(r, coaid, accountsIds...)
 	if err != nil {
 		return nil, err
 	}
 	result := make([]int, len(accountsIds))
-	for i, id := range accountsIds {
+	for i, a := range accounts {
 		result[i] = -1
-		for j, a := range accounts {
-			if a.Id == id && a.Tags.ContainsAll(tags) {
-				result[i] = j
-			}
+		if a != nil && a.Tags.ContainsAll(tags) && (includeRemoved || a.Removed.IsZero()) {
+			result[i] = positions[a.Id]
 		}
 	}
 	return result, nil
 }
 
-// TODO: DeleteAccount
+func (r *CoaRepository) DeleteAccount(coaid string, id string) error {
+	// the chart changes only when its retained earnings account is removed,
+	// which cannot change while the charts are locked for reading
+	unlock := r.locks.writeAccounts(coaid, false)
+	coa, err := r.getChartOfAccounts(coaid, false)
+	if err == nil && coa != nil && id != "" && coa.RetainedEarningsAccount == id {
+		unlock()
+		unlock = r.locks.writeAccounts(coaid, true)
+	}
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	return r.atomically(func(r *CoaRepository) error {
+		return r.deleteAccount(coaid, id)
+	})
+}
+
+func (r *CoaRepository) deleteAccount(coaid string, id string) error {
+	if coaid == "" {
+		return ErrEmptyCoaid
+	}
+	err := r.checkChartOfAccounts(coaid)
+	if err != nil {
+		return err
+	}
+	account, err := r.getAccount(coaid, id, false)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return fmt.Errorf("%w: %v", ErrAccountNotFound, id)
+	}
+	children, err := r.layout.children(r, coaid, id)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return ErrAccountHasChildren
+	}
+	now := time.Now()
+	account.Removed = now
+	account.AsOf = now
+	account.Version++
+	changed := Accounts{account}
+	if account.Parent != "" {
+		siblings, err := r.layout.children(r, coaid, account.Parent)
+		if err != nil {
+			return err
+		}
+		parent, err := r.getAccount(coaid, account.Parent, false)
+		if err != nil {
+			return err
+		}
+		if parent != nil && len(siblings) == 1 {
+			if i := parent.Tags.IndexOf("summary"); i != -1 {
+				parent.Tags = append(parent.Tags[:i], parent.Tags[i+1:]...)
+			}
+			if !parent.Tags.Contains("detail") {
+				parent.Tags = append(parent.Tags, "detail")
+			}
+			parent.AsOf = now
+			parent.Version++
+			changed = append(changed, parent)
+		}
+	}
+	err = r.saveAccounts(coaid, changed...)
+	if err != nil {
+		return err
+	}
+	coa, err := r.getChartOfAccounts(coaid, false)
+	if err != nil {
+		return err
+	}
+	if coa != nil && coa.RetainedEarningsAccount == account.Id {
+		coa.RetainedEarningsAccount = ""
+		_, err = r.saveChartOfAccounts(coa)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 func (coa *ChartOfAccounts) ValidationMessage() string {
+	return validationMessage(coa.Validate())
+}
+
+func (coa *ChartOfAccounts) Validate() error {
+	var errs ValidationErrors
 	if len(strings.TrimSpace(coa.Name)) == 0 {
-		return "The name must be informed"
+		errs.add(ErrNameRequired, "name")
 	}
-	return ""
+	if coa.Mask != "" && mask(coa.Mask).levels() == nil {
+		errs.add(ErrInvalidMask, "mask", "mask", coa.Mask)
+	}
+	coa.checkPolicies(&errs)
+	return errs.err()
 }
 
 func (account *Account) ValidationMessage(coaid string, r *CoaRepository) string {
+	return validationMessage(account.Validate(coaid, r))
+}
+
+func (account *Account) Validate(coaid string, r *CoaRepository) error {
+	defer r.locks.readAccounts(coaid)()
+	return account.validate(coaid, r)
+}
+
+// validate is Validate for callers holding the chart's lock.
+func (account *Account) validate(coaid string, r *CoaRepository) error {
+	var errs ValidationErrors
 	if len(strings.TrimSpace(account.Number)) == 0 {
-		return "The number must be informed"
+		errs.add(ErrNumberRequired, "number")
 	}
 	if len(strings.TrimSpace(account.Name)) == 0 {
-		return "The name must be informed"
+		errs.add(ErrNameRequired, "name")
 	}
 	if !account.Tags.Contains("balanceSheet") && !account.Tags.Contains("incomeStatement") {
-		return "The financial statement must be informed"
+		errs.add(ErrFinancialStatementRequired, "tags")
 	}
 	if account.Tags.Contains("balanceSheet") && account.Tags.Contains("incomeStatement") {
-		return "The statement must be either balance sheet or income statement"
+		errs.add(ErrAmbiguousFinancialStatement, "tags")
 	}
 	if !account.Tags.Contains("increaseOnDebit") && !account.Tags.Contains("increaseOnCredit") {
-		return "The normal balance must be informed"
+		errs.add(ErrNormalBalanceRequired, "tags")
 	}
 	if account.Tags.Contains("increaseOnDebit") && account.Tags.Contains("increaseOnCredit") {
-		return "The normal balance must be either debit or credit"
+		errs.add(ErrAmbiguousNormalBalance, "tags")
 	}
 	count := 0
 	for _, p := range account.Tags {
-		if inheritedProperties[p] == "income statement attribute" {
+		if inheritedProperties[p] == "incomeStatementAttribute" {
 			count++
 		}
 	}
 	if count > 1 {
-		return "Only one income statement attribute is allowed"
+		errs.add(ErrMultipleIncomeStatementAttributes, "tags")
 	}
-	if account.Id == "" {
-		aa, err := r.AllAccounts(coaid)
+	if account.Id == "" && account.Number != "" {
+		a, err := r.layout.byNumber(r, coaid, account.Number)
 		if err != nil {
-			return err.Error()
+			return err
 		}
-		for _, a := range aa {
-			if a.Number == account.Number {
-				return "An account with this number already exists"
-			}
+		if a != nil {
+			errs.add(ErrDuplicateNumber, "number", "number", account.Number)
+		}
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return err
+	}
+	// the mask applies to numbers being assigned, MoveAccount and
+	// RenumberAccounts check it themselves
+	if account.Id == "" && account.Parent == "" {
+		coa.checkMask(&errs, account.Number, "")
+	}
+	if account.Id == "" {
+		level, err := r.level(coaid, account.Parent)
+		if err != nil {
+			return err
 		}
+		coa.checkDepth(&errs, "parent", account.Number, level+1)
 	}
 	if account.Parent != "" {
-		parent, err := r.GetAccount(coaid, account.Parent)
+		parent, err := r.getAccount(coaid, account.Parent, false)
 		if err != nil {
-			return err.Error()
+			return err
 		}
 		if parent == nil {
-			return "Parent not found: " + account.Parent
+			errs.add(ErrParentNotFound, "parent", "parent", account.Parent)
+			return r.localize(errs.err())
 		}
-		if !strings.HasPrefix(account.Number, parent.Number) {
-			return "The number must start with parent's number"
+		if account.Id == "" {
+			coa.checkMask(&errs, account.Number, parent.Number)
 		}
-		for key, value := range inheritedProperties {
+		if account.Number != "" && !strings.HasPrefix(account.Number, parent.Number) {
+			errs.add(ErrNumberPrefixMismatch, "number", "number", account.Number, "parentNumber", parent.Number)
+		}
+		keys := make([]string, 0, len(inheritedProperties))
+		for key := range inheritedProperties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
 			if parent.Tags.Contains(key) && !account.Tags.Contains(key) {
-				return "The " + value + " must be same as the parent"
+				errs.add(ErrInheritedPropertyMismatch, "tags", "tag", key, "property", inheritedProperties[key])
 			}
 		}
 	}
+	return r.localize(errs.err())
+}
+
+func (r *CoaRepository) localize(err error) error {
+	if errs, ok := err.(ValidationErrors); ok {
+		for _, e := range errs {
+			e.Message = r.catalog.Message(e)
+		}
+	}
+	return err
+}
+
+func validationMessage(err error) string {
+	if errs, ok := err.(ValidationErrors); ok {
+		return errs[0].Error()
+	}
+	if err != nil {
+		return err.Error()
+	}
 	return ""
 }
 
 func (r *CoaRepository) put(key string, v interface{}) error {
-	// data, err := json.Marshal(v)
-	data, err := v.(msgp.Marshaler).MarshalMsg(nil)
+	data, err := r.codec.Marshal(v)
 	if err != nil {
 		return err
 	}
-	return r.store.Put([]byte(key), data)
+	return r.store.Put([]byte(key), seal(r.codec, data))
 }
 
 func (r *CoaRepository) get(key string, v interface{}) error {
@@ -346,15 +745,24 @@ func (r *CoaRepository) get(key string, v interface{}) error {
 	if err != nil {
 		return err
 	}
+	if err := r.decode(key, data, v); err != nil {
+		return err
+	}
+	if r.rewriteOnRead && outdated(data) {
+		return r.rewrite(key)
+	}
+	return nil
+}
+
+func (r *CoaRepository) decode(key string, data []byte, v interface{}) error {
 	if data == nil || len(data) == 0 {
 		return nil
 	}
-	_, err = v.(msgp.Unmarshaler).UnmarshalMsg(data)
-	// err = json.Unmarshal(data, v)
+	codec, body, err := r.upgrade(key, data)
 	if err != nil {
 		return err
 	}
-	return nil
+	return codec.Unmarshal(body, v)
 }
 
 func (aa Accounts) String() string {
@@ -81,6 +81,11 @@ func (z *Account) DecodeMsg(dc *msgp.Reader) (err error) {
 			if err != nil {
 				return
 			}
+		case "Version":
+			z.Version, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -93,9 +98,9 @@ func (z *Account) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Account) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 9
+	// map header, size 10
 	// write "Id"
-	err = en.Append(0x89, 0xa2, 0x49, 0x64)
+	err = en.Append(0x8a, 0xa2, 0x49, 0x64)
 	if err != nil {
 		return err
 	}
@@ -181,15 +186,24 @@ func (z *Account) EncodeMsg(en *msgp.Writer) (err error) {
 	if err != nil {
 		return
 	}
+	// write "Version"
+	err = en.Append(0xa7, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt(z.Version)
+	if err != nil {
+		return
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Account) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 9
+	// map header, size 10
 	// string "Id"
-	o = append(o, 0x89, 0xa2, 0x49, 0x64)
+	o = append(o, 0x8a, 0xa2, 0x49, 0x64)
 	o = msgp.AppendString(o, z.Id)
 	// string "Number"
 	o = append(o, 0xa6, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72)
@@ -218,6 +232,9 @@ func (z *Account) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "Removed"
 	o = append(o, 0xa7, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64)
 	o = msgp.AppendTime(o, z.Removed)
+	// string "Version"
+	o = append(o, 0xa7, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e)
+	o = msgp.AppendInt(o, z.Version)
 	return
 }
 
@@ -294,6 +311,11 @@ func (z *Account) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			if err != nil {
 				return
 			}
+		case "Version":
+			z.Version, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -311,7 +333,7 @@ func (z *Account) Msgsize() (s int) {
 	for za0001 := range z.Tags {
 		s += msgp.StringPrefixSize + len(z.Tags[za0001])
 	}
-	s += 7 + msgp.StringPrefixSize + len(z.Parent) + 5 + msgp.StringPrefixSize + len(z.User) + 5 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.TimeSize
+	s += 7 + msgp.StringPrefixSize + len(z.Parent) + 5 + msgp.StringPrefixSize + len(z.User) + 5 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.IntSize
 	return
 }
 
@@ -483,6 +505,36 @@ func (z *ChartOfAccounts) DecodeMsg(dc *msgp.Reader) (err error) {
 			if err != nil {
 				return
 			}
+		case "Version":
+			z.Version, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
+		case "Mask":
+			z.Mask, err = dc.ReadString()
+			if err != nil {
+				return
+			}
+		case "NaturalOrdering":
+			z.NaturalOrdering, err = dc.ReadBool()
+			if err != nil {
+				return
+			}
+		case "MaxDepth":
+			z.MaxDepth, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
+		case "MinPostingLevel":
+			z.MinPostingLevel, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
+		case "MinTopLevelGroups":
+			z.MinTopLevelGroups, err = dc.ReadInt()
+			if err != nil {
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -495,9 +547,9 @@ func (z *ChartOfAccounts) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *ChartOfAccounts) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 7
+	// map header, size 13
 	// write "Id"
-	err = en.Append(0x87, 0xa2, 0x49, 0x64)
+	err = en.Append(0x8d, 0xa2, 0x49, 0x64)
 	if err != nil {
 		return err
 	}
@@ -559,15 +611,69 @@ func (z *ChartOfAccounts) EncodeMsg(en *msgp.Writer) (err error) {
 	if err != nil {
 		return
 	}
+	// write "Version"
+	err = en.Append(0xa7, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt(z.Version)
+	if err != nil {
+		return
+	}
+	// write "Mask"
+	err = en.Append(0xa4, 0x4d, 0x61, 0x73, 0x6b)
+	if err != nil {
+		return err
+	}
+	err = en.WriteString(z.Mask)
+	if err != nil {
+		return
+	}
+	// write "NaturalOrdering"
+	err = en.Append(0xaf, 0x4e, 0x61, 0x74, 0x75, 0x72, 0x61, 0x6c, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x69, 0x6e, 0x67)
+	if err != nil {
+		return err
+	}
+	err = en.WriteBool(z.NaturalOrdering)
+	if err != nil {
+		return
+	}
+	// write "MaxDepth"
+	err = en.Append(0xa8, 0x4d, 0x61, 0x78, 0x44, 0x65, 0x70, 0x74, 0x68)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt(z.MaxDepth)
+	if err != nil {
+		return
+	}
+	// write "MinPostingLevel"
+	err = en.Append(0xaf, 0x4d, 0x69, 0x6e, 0x50, 0x6f, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt(z.MinPostingLevel)
+	if err != nil {
+		return
+	}
+	// write "MinTopLevelGroups"
+	err = en.Append(0xb1, 0x4d, 0x69, 0x6e, 0x54, 0x6f, 0x70, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x73)
+	if err != nil {
+		return err
+	}
+	err = en.WriteInt(z.MinTopLevelGroups)
+	if err != nil {
+		return
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *ChartOfAccounts) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 7
+	// map header, size 13
 	// string "Id"
-	o = append(o, 0x87, 0xa2, 0x49, 0x64)
+	o = append(o, 0x8d, 0xa2, 0x49, 0x64)
 	o = msgp.AppendString(o, z.Id)
 	// string "Name"
 	o = append(o, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
@@ -587,6 +693,24 @@ func (z *ChartOfAccounts) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "Removed"
 	o = append(o, 0xa7, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64)
 	o = msgp.AppendTime(o, z.Removed)
+	// string "Version"
+	o = append(o, 0xa7, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e)
+	o = msgp.AppendInt(o, z.Version)
+	// string "Mask"
+	o = append(o, 0xa4, 0x4d, 0x61, 0x73, 0x6b)
+	o = msgp.AppendString(o, z.Mask)
+	// string "NaturalOrdering"
+	o = append(o, 0xaf, 0x4e, 0x61, 0x74, 0x75, 0x72, 0x61, 0x6c, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x69, 0x6e, 0x67)
+	o = msgp.AppendBool(o, z.NaturalOrdering)
+	// string "MaxDepth"
+	o = append(o, 0xa8, 0x4d, 0x61, 0x78, 0x44, 0x65, 0x70, 0x74, 0x68)
+	o = msgp.AppendInt(o, z.MaxDepth)
+	// string "MinPostingLevel"
+	o = append(o, 0xaf, 0x4d, 0x69, 0x6e, 0x50, 0x6f, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x4c, 0x65, 0x76, 0x65, 0x6c)
+	o = msgp.AppendInt(o, z.MinPostingLevel)
+	// string "MinTopLevelGroups"
+	o = append(o, 0xb1, 0x4d, 0x69, 0x6e, 0x54, 0x6f, 0x70, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x73)
+	o = msgp.AppendInt(o, z.MinTopLevelGroups)
 	return
 }
 
@@ -641,6 +765,36 @@ func (z *ChartOfAccounts) UnmarshalMsg(bts []byte) (o []byte, err error) {
 			if err != nil {
 				return
 			}
+		case "Version":
+			z.Version, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
+		case "Mask":
+			z.Mask, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				return
+			}
+		case "NaturalOrdering":
+			z.NaturalOrdering, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				return
+			}
+		case "MaxDepth":
+			z.MaxDepth, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
+		case "MinPostingLevel":
+			z.MinPostingLevel, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
+		case "MinTopLevelGroups":
+			z.MinTopLevelGroups, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -654,7 +808,7 @@ func (z *ChartOfAccounts) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *ChartOfAccounts) Msgsize() (s int) {
-	s = 1 + 3 + msgp.StringPrefixSize + len(z.Id) + 5 + msgp.StringPrefixSize + len(z.Name) + 24 + msgp.StringPrefixSize + len(z.RetainedEarningsAccount) + 5 + msgp.StringPrefixSize + len(z.User) + 5 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.TimeSize
+	s = 1 + 3 + msgp.StringPrefixSize + len(z.Id) + 5 + msgp.StringPrefixSize + len(z.Name) + 24 + msgp.StringPrefixSize + len(z.RetainedEarningsAccount) + 5 + msgp.StringPrefixSize + len(z.User) + 5 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.TimeSize + 8 + msgp.IntSize + 5 + msgp.StringPrefixSize + len(z.Mask) + 16 + msgp.BoolSize + 9 + msgp.IntSize + 16 + msgp.IntSize + 18 + msgp.IntSize
 	return
 }
 
@@ -774,3 +928,80 @@ func (z ChartsOfAccounts) Msgsize() (s int) {
 	}
 	return
 }
+
+// DecodeMsg implements msgp.Decodable
+func (z *idList) DecodeMsg(dc *msgp.Reader) (err error) {
+	var zb0002 uint32
+	zb0002, err = dc.ReadArrayHeader()
+	if err != nil {
+		return
+	}
+	if cap((*z)) >= int(zb0002) {
+		(*z) = (*z)[:zb0002]
+	} else {
+		(*z) = make(idList, zb0002)
+	}
+	for zb0001 := range *z {
+		(*z)[zb0001], err = dc.ReadString()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z idList) EncodeMsg(en *msgp.Writer) (err error) {
+	err = en.WriteArrayHeader(uint32(len(z)))
+	if err != nil {
+		return
+	}
+	for zb0003 := range z {
+		err = en.WriteString(z[zb0003])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z idList) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	o = msgp.AppendArrayHeader(o, uint32(len(z)))
+	for zb0003 := range z {
+		o = msgp.AppendString(o, z[zb0003])
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *idList) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0002 uint32
+	zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		return
+	}
+	if cap((*z)) >= int(zb0002) {
+		(*z) = (*z)[:zb0002]
+	} else {
+		(*z) = make(idList, zb0002)
+	}
+	for zb0001 := range *z {
+		(*z)[zb0001], bts, err = msgp.ReadStringBytes(bts)
+		if err != nil {
+			return
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z idList) Msgsize() (s int) {
+	s = msgp.ArrayHeaderSize
+	for zb0003 := range z {
+		s += msgp.StringPrefixSize + len(z[zb0003])
+	}
+	return
+}
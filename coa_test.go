@@ -1,6 +1,7 @@
 package coa
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -95,6 +96,88 @@ func TestIfAllChartsOfAccountsIsSorted(t *testing.T) {
 	}
 }
 
+func TestRemoveAndRestoreChartOfAccounts(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa1, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "1"})
+	check(t, err)
+	coa2, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "2"})
+	check(t, err)
+	check(t, r.RemoveChartOfAccounts(coa1.Id))
+	coas, err := r.AllChartsOfAccounts()
+	check(t, err)
+	if len(coas) != 1 || coas[0].Id != coa2.Id {
+		t.Errorf("Expected only coa2 but was %v", coas)
+	}
+	coa, err := r.GetChartOfAccounts(coa1.Id)
+	check(t, err)
+	if coa != nil {
+		t.Errorf("Expected removed coa to be hidden but was %v", coa)
+	}
+	coa, err = r.GetChartOfAccountsIncludingRemoved(coa1.Id)
+	check(t, err)
+	if coa == nil || coa.Removed.IsZero() {
+		t.Errorf("Expected removed coa with Removed set but was %v", coa)
+	}
+	coas, err = r.AllChartsOfAccountsIncludingRemoved()
+	check(t, err)
+	if len(coas) != 2 {
+		t.Errorf("Expected 2 coas but was %v", len(coas))
+	}
+	_, err = r.SaveAccount(coa1.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	if err == nil {
+		t.Error("Expected error saving an account in a removed coa")
+	}
+	coa2.Name = "22"
+	_, err = r.SaveChartOfAccounts(coa2)
+	check(t, err)
+	coas, err = r.AllChartsOfAccountsIncludingRemoved()
+	check(t, err)
+	if len(coas) != 2 {
+		t.Errorf("Expected removed coa to be kept but was %v", coas)
+	}
+	if _, err := r.SaveChartOfAccounts(coa); err == nil {
+		t.Error("Expected error saving a removed coa")
+	}
+	check(t, r.RestoreChartOfAccounts(coa1.Id))
+	coa, err = r.GetChartOfAccounts(coa1.Id)
+	check(t, err)
+	if coa == nil || !coa.Removed.IsZero() {
+		t.Errorf("Expected restored coa but was %v", coa)
+	}
+	_, err = r.SaveAccount(coa1.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	if err := r.RemoveChartOfAccounts("unknown"); err == nil {
+		t.Error("Expected error removing an unknown coa")
+	}
+	for _, tags := range [][]string{{"balanceSheet", "increaseOnDebit"}, {"balanceSheet", "increaseOnDebit", "retainedEarnings"}} {
+		_, err = r.SaveAccount("unknown", &Account{Number: "1", Name: "a1", Tags: tags})
+		if !errors.Is(err, ErrChartOfAccountsNotFound) {
+			t.Errorf("Expected ErrChartOfAccountsNotFound saving %v in an unknown coa but was %v", tags, err)
+		}
+	}
+	accounts, err := r.AllAccounts("unknown")
+	check(t, err)
+	if len(accounts) != 0 {
+		t.Errorf("Expected nothing written to an unknown coa but was %v", accounts)
+	}
+}
+
+func TestSaveChartOfAccountsIgnoresRemoved(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", Removed: time.Now()})
+	check(t, err)
+	created := coa.Created
+	coa.Removed = time.Now()
+	coa.Created = time.Time{}
+	coa, err = r.SaveChartOfAccounts(coa)
+	check(t, err)
+	coas, err := r.AllChartsOfAccounts()
+	check(t, err)
+	if len(coas) != 1 || !coas[0].Removed.IsZero() || !coas[0].Created.Equal(created) {
+		t.Errorf("Expected a live chart keeping its creation time but was %v", coas)
+	}
+}
+
 func TestSaveAccount(t *testing.T) {
 	r := NewCoaRepository(store{})
 	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
@@ -181,6 +264,137 @@ func TestIfAllAccountsIsSorted(t *testing.T) {
 	}
 }
 
+func TestSaveAccountIgnoresRemoved(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: tags, Removed: time.Now()})
+	check(t, err)
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "1.1", Name: "a11", Parent: a1.Id, Tags: tags})
+	check(t, err)
+	a1, err = r.GetAccount(coa.Id, a1.Id)
+	check(t, err)
+	if a1 == nil {
+		t.Fatal("Expected an account created with Removed set to be live")
+	}
+	a1.Removed = time.Now()
+	_, err = r.SaveAccount(coa.Id, a1)
+	check(t, err)
+	accounts, err := r.AllAccounts(coa.Id)
+	check(t, err)
+	if len(accounts) != 2 {
+		t.Errorf("Expected updating Removed to leave a1 live but was %v", accounts)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a11, err := r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id,
+		Tags: []string{"balanceSheet", "increaseOnDebit", "retainedEarnings"}})
+	check(t, err)
+	if err := r.DeleteAccount(coa.Id, a1.Id); err == nil {
+		t.Error("Expected error removing an account with children")
+	}
+	check(t, r.DeleteAccount(coa.Id, a11.Id))
+	if err := r.DeleteAccount(coa.Id, a11.Id); err == nil {
+		t.Error("Expected error removing an account twice")
+	}
+	accounts, err := r.AllAccounts(coa.Id)
+	check(t, err)
+	if len(accounts) != 1 || accounts[0].Id != a1.Id {
+		t.Fatalf("Expected only a1 but was %v", accounts)
+	}
+	if !accounts[0].Tags.Contains("detail") || accounts[0].Tags.Contains("summary") {
+		t.Errorf("Expected a1 to be detail again but tags were %v", accounts[0].Tags)
+	}
+	a, err := r.GetAccount(coa.Id, a11.Id)
+	check(t, err)
+	if a != nil {
+		t.Errorf("Expected removed account to be hidden but was %v", a)
+	}
+	a, err = r.GetAccountIncludingRemoved(coa.Id, a11.Id)
+	check(t, err)
+	if a == nil || a.Removed.IsZero() {
+		t.Errorf("Expected removed account with Removed set but was %v", a)
+	}
+	accounts, err = r.AllAccountsIncludingRemoved(coa.Id)
+	check(t, err)
+	if len(accounts) != 2 {
+		t.Errorf("Expected 2 accounts but was %v", len(accounts))
+	}
+	idx, err := r.Indexes(coa.Id, []string{a1.Id, a11.Id}, nil)
+	check(t, err)
+	if idx[0] != 0 || idx[1] != -1 {
+		t.Errorf("Expected 0 -1 but was %v %v", idx[0], idx[1])
+	}
+	idx, err = r.IndexesIncludingRemoved(coa.Id, []string{a1.Id, a11.Id}, nil)
+	check(t, err)
+	if idx[0] != 0 || idx[1] != 1 {
+		t.Errorf("Expected 0 1 but was %v %v", idx[0], idx[1])
+	}
+	coa, err = r.GetChartOfAccounts(coa.Id)
+	check(t, err)
+	if coa.RetainedEarningsAccount != "" {
+		t.Errorf("Expected empty but was %v", coa.RetainedEarningsAccount)
+	}
+	if _, err := r.SaveAccount(coa.Id, a11); err == nil {
+		t.Error("Expected error saving a removed account")
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+}
+
+func TestSaveDetectsConflicts(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	if coa.Version != 1 {
+		t.Errorf("Expected version 1 but was %v", coa.Version)
+	}
+	coa1, err := r.GetChartOfAccounts(coa.Id)
+	check(t, err)
+	coa2, err := r.GetChartOfAccounts(coa.Id)
+	check(t, err)
+	coa1.Name = "coa1"
+	_, err = r.SaveChartOfAccounts(coa1)
+	check(t, err)
+	if coa1.Version != 2 {
+		t.Errorf("Expected version 2 but was %v", coa1.Version)
+	}
+	coa2.Name = "coa2"
+	if _, err := r.SaveChartOfAccounts(coa2); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict but was %v", err)
+	}
+	a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a1, err := r.GetAccount(coa.Id, a.Id)
+	check(t, err)
+	a2, err := r.GetAccount(coa.Id, a.Id)
+	check(t, err)
+	a1.Name = "a1a1"
+	_, err = r.SaveAccount(coa.Id, a1)
+	check(t, err)
+	a2.Name = "a2a2"
+	if _, err := r.SaveAccount(coa.Id, a2); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict but was %v", err)
+	}
+	a, err = r.GetAccount(coa.Id, a.Id)
+	check(t, err)
+	if a.Name != "a1a1" || a.Version != 2 {
+		t.Errorf("Expected a1a1 version 2 but was %v version %v", a.Name, a.Version)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	if _, err := r.SaveAccount(coa.Id, a); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict after the parent became summary but was %v", err)
+	}
+}
+
 func check(t *testing.T, err error) {
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,172 @@
+package coa
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// Codec encodes the values kept in the store. Whatever the codec used for
+// writing, values are decoded with the codec they were written with.
+type Codec interface {
+	// ID identifies the codec in stored values. 1, 2 and 3 are taken by
+	// Msgp, JSON and CBOR.
+	ID() byte
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes into v, a pointer to one of the package's types or,
+	// for migrations, to an interface{}.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	Msgp Codec = msgpCodec{}
+	JSON Codec = jsonCodec{}
+	CBOR Codec = cborCodec{}
+)
+
+// WithCodec sets the codec values are written with, Msgp by default.
+func WithCodec(c Codec) Option {
+	return func(r *CoaRepository) { r.codec = c }
+}
+
+func (r *CoaRepository) codecFor(id byte) (Codec, bool) {
+	for _, c := range []Codec{r.codec, Msgp, JSON, CBOR} {
+		if c.ID() == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+type msgpCodec struct{}
+
+func (msgpCodec) ID() byte { return 1 }
+
+func (msgpCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(msgp.Marshaler); ok {
+		return m.MarshalMsg(nil)
+	}
+	return msgp.AppendIntf(nil, v)
+}
+
+func (msgpCodec) Unmarshal(data []byte, v interface{}) error {
+	if i, ok := v.(*interface{}); ok {
+		var err error
+		*i, _, err = msgp.ReadIntfBytes(data)
+		return err
+	}
+	_, err := v.(msgp.Unmarshaler).UnmarshalMsg(data)
+	return err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return 2 }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(record(v))
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalRecord(data, v, json.Unmarshal)
+}
+
+var (
+	cborEnc, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	cborDec, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+)
+
+type cborCodec struct{}
+
+func (cborCodec) ID() byte { return 3 }
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cborEnc.Marshal(record(v))
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalRecord(data, v, cborDec.Unmarshal)
+}
+
+// Created and Removed are left out of the JSON of charts and accounts, so
+// JSON and CBOR encode them through records keeping them.
+type chartRecord struct {
+	ChartOfAccounts
+	Created time.Time `json:"created"`
+	Removed time.Time `json:"removed"`
+}
+
+type accountRecord struct {
+	Account
+	Created time.Time `json:"created"`
+	Removed time.Time `json:"removed"`
+}
+
+func record(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *ChartOfAccounts:
+		return chartRecord{*v, v.Created, v.Removed}
+	case ChartsOfAccounts:
+		records := make([]chartRecord, len(v))
+		for i, coa := range v {
+			records[i] = chartRecord{*coa, coa.Created, coa.Removed}
+		}
+		return records
+	case *Account:
+		return accountRecord{*v, v.Created, v.Removed}
+	case Accounts:
+		records := make([]accountRecord, len(v))
+		for i, a := range v {
+			records[i] = accountRecord{*a, a.Created, a.Removed}
+		}
+		return records
+	}
+	return v
+}
+
+func unmarshalRecord(data []byte, v interface{}, unmarshal func([]byte, interface{}) error) error {
+	switch v := v.(type) {
+	case *ChartOfAccounts:
+		var rec chartRecord
+		if err := unmarshal(data, &rec); err != nil {
+			return err
+		}
+		*v = rec.ChartOfAccounts
+		v.Created, v.Removed = rec.Created, rec.Removed
+	case *ChartsOfAccounts:
+		var records []chartRecord
+		if err := unmarshal(data, &records); err != nil {
+			return err
+		}
+		*v = make(ChartsOfAccounts, len(records))
+		for i := range records {
+			coa := records[i].ChartOfAccounts
+			coa.Created, coa.Removed = records[i].Created, records[i].Removed
+			(*v)[i] = &coa
+		}
+	case *Account:
+		var rec accountRecord
+		if err := unmarshal(data, &rec); err != nil {
+			return err
+		}
+		*v = rec.Account
+		v.Created, v.Removed = rec.Created, rec.Removed
+	case *Accounts:
+		var records []accountRecord
+		if err := unmarshal(data, &records); err != nil {
+			return err
+		}
+		*v = make(Accounts, len(records))
+		for i := range records {
+			a := records[i].Account
+			a.Created, a.Removed = records[i].Created, records[i].Removed
+			(*v)[i] = &a
+		}
+	default:
+		return unmarshal(data, v)
+	}
+	return nil
+}
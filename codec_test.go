@@ -0,0 +1,95 @@
+package coa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{Msgp, JSON, CBOR} {
+		for _, layout := range []Layout{BlobLayout, PerAccountLayout} {
+			name := fmt.Sprintf("codec %v layout %v", codec.ID(), layout)
+			s := store{}
+			r := NewCoaRepository(s, WithCodec(codec), WithLayout(layout))
+			coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+			check(t, err)
+			removed, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "removed"})
+			check(t, err)
+			check(t, r.RemoveChartOfAccounts(removed.Id))
+			a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			a11, err := r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			check(t, r.DeleteAccount(coa.Id, a11.Id))
+			for _, other := range []Codec{Msgp, JSON, CBOR} {
+				r := NewCoaRepository(s, WithCodec(other), WithLayout(layout))
+				coas, err := r.AllChartsOfAccountsIncludingRemoved()
+				check(t, err)
+				if len(coas) != 2 || coas[1].Removed.IsZero() || coas[0].Created.IsZero() || coas[0].Version != 1 {
+					t.Errorf("%v read with %v: Unexpected charts %v %v", name, other.ID(), coas[0], coas[1])
+				}
+				accounts, err := r.AllAccountsIncludingRemoved(coa.Id)
+				check(t, err)
+				if len(accounts) != 2 || accounts[1].Removed.IsZero() || !accounts[1].Created.Equal(a11.Created) ||
+					!accounts[0].Tags.Contains("detail") || accounts[0].Version != 3 {
+					t.Errorf("%v read with %v: Unexpected accounts %v", name, other.ID(), accounts)
+				}
+			}
+		}
+	}
+}
+
+func TestJSONCanBeInspected(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s, WithCodec(JSON))
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	_, _, body := open(s["accounts/"+coa.Id])
+	var accounts []map[string]interface{}
+	check(t, json.Unmarshal(body, &accounts))
+	if len(accounts) != 1 || accounts[0]["name"] != "a1" || accounts[0]["removed"] == nil {
+		t.Errorf("Expected the account as JSON but was %s", body)
+	}
+}
+
+func TestMigrationsWithJSONNames(t *testing.T) {
+	m := NewMigrations()
+	m.Register(0, func(key string, value interface{}) (interface{}, error) {
+		for _, a := range value.([]interface{}) {
+			a := a.(map[string]interface{})
+			if _, ok := a["Id"]; !ok {
+				return nil, errors.New("expected fields keyed by Go names")
+			}
+			a["Name"] = a["nome"]
+			delete(a, "nome")
+		}
+		return value, nil
+	})
+	for _, codec := range []Codec{JSON, CBOR} {
+		body, err := codec.Marshal([]interface{}{map[string]interface{}{"_id": "a1", "number": "1", "nome": "old name"}})
+		check(t, err)
+		s := store{}
+		data := []byte{}
+		data = append(data, envelopeMagic...)
+		data = append(data, 0, codec.ID())
+		s["accounts/c1"] = append(data, body...)
+		accounts, err := NewCoaRepository(s, WithMigrations(m), WithRewriteOnRead()).AllAccounts("c1")
+		check(t, err)
+		if len(accounts) != 1 || accounts[0].Name != "old name" {
+			t.Fatalf("%v: Expected the migrated account but was %v", codec.ID(), accounts)
+		}
+		version, id, body := open(s["accounts/c1"])
+		if version != SchemaVersion || id != codec.ID() {
+			t.Errorf("%v: Expected version %v in the same codec but was %v in %v", codec.ID(), SchemaVersion, version, id)
+		}
+		var rewritten []map[string]interface{}
+		check(t, codec.Unmarshal(body, &rewritten))
+		if len(rewritten) != 1 || rewritten[0]["name"] != "old name" {
+			t.Errorf("%v: Expected the rewritten value to use JSON names but was %v", codec.ID(), rewritten)
+		}
+	}
+}
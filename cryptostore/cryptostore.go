@@ -0,0 +1,261 @@
+// Package cryptostore is a coa.KeyValueStore decorator encrypting values
+// with AES-GCM, so the backing store only sees ciphertext.
+//
+// Each value is stored as the length of the key id, the key id, the nonce
+// and the sealed value. The store key is authenticated along with the
+// value, so a value copied to another key fails to decrypt. Keys are not
+// encrypted, and empty values, which CoaRepository reads as missing, are
+// stored as is.
+package cryptostore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/go-accounting/coa"
+)
+
+// KeyProvider hands out AES keys of 16, 24 or 32 bytes. Values are
+// encrypted with the current key and decrypted with the key whose id they
+// carry, so keys can be rotated while old values remain readable.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+var ErrUnknownKey = errors.New("cryptostore: unknown key")
+
+// Keys is a KeyProvider holding its keys in memory.
+type Keys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (k Keys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+func (k Keys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Store encrypts the values of the wrapped store.
+type Store struct {
+	kv   coa.KeyValueStore
+	keys KeyProvider
+}
+
+// New wraps kv. The returned store implements coa.ScannableKeyValueStore
+// and coa.TransactionalKeyValueStore when kv does.
+func New(kv coa.KeyValueStore, keys KeyProvider) coa.KeyValueStore {
+	return wrap(&Store{kv, keys})
+}
+
+func wrap(s *Store) coa.KeyValueStore {
+	_, scannable := s.kv.(coa.ScannableKeyValueStore)
+	_, transactional := s.kv.(coa.TransactionalKeyValueStore)
+	switch {
+	case scannable && transactional:
+		return txScanStore{scanStore{s}}
+	case scannable:
+		return scanStore{s}
+	case transactional:
+		return txStore{s}
+	}
+	return s
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	data, err := s.kv.Get(key)
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+	return s.decrypt(key, data)
+}
+
+func (s *Store) Put(key []byte, value []byte) error {
+	if len(value) == 0 {
+		return s.kv.Put(key, value)
+	}
+	data, err := s.encrypt(key, value)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(key, data)
+}
+
+func (s *Store) encrypt(key []byte, value []byte) ([]byte, error) {
+	id, k, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("cryptostore: key id too long: %v", id)
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 1+len(id)+aead.NonceSize(), 1+len(id)+aead.NonceSize()+len(value)+aead.Overhead())
+	data[0] = byte(len(id))
+	copy(data[1:], id)
+	nonce := data[1+len(id):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(data, nonce, value, key), nil
+}
+
+func (s *Store) decrypt(key []byte, data []byte) ([]byte, error) {
+	n := int(data[0])
+	if len(data) < 1+n {
+		return nil, fmt.Errorf("cryptostore: malformed value for %s", key)
+	}
+	k, err := s.keys.Key(keyId(data))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	data = data[1+n:]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("cryptostore: malformed value for %s", key)
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], key)
+	if err != nil {
+		return nil, fmt.Errorf("cryptostore: cannot decrypt %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Rotate encrypts again, with the current key, the values under keys
+// starting with prefix that were encrypted with another key. kv must have
+// been returned by New for a coa.ScannableKeyValueStore. When that store is
+// also a coa.TransactionalKeyValueStore, each value is read and written
+// back in a transaction, so Rotate may run alongside writers; otherwise a
+// value written between the read and the write is overwritten with the
+// older one, and Rotate must not run alongside writers.
+func Rotate(kv coa.KeyValueStore, prefix []byte) error {
+	r, ok := kv.(interface{ store() *Store })
+	if !ok {
+		return errors.New("cryptostore: not an encrypting store")
+	}
+	s := r.store()
+	ss, ok := s.kv.(coa.ScannableKeyValueStore)
+	if !ok {
+		return errors.New("cryptostore: the store cannot be scanned")
+	}
+	id, _, err := s.keys.CurrentKey()
+	if err != nil {
+		return err
+	}
+	var keys [][]byte
+	err = ss.Scan(prefix, func(key []byte, data []byte) error {
+		if len(data) > 0 && keyId(data) != id {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ts, transactional := s.kv.(coa.TransactionalKeyValueStore)
+	for _, key := range keys {
+		if !transactional {
+			if err := s.reencrypt(key, id); err != nil {
+				return err
+			}
+			continue
+		}
+		err := ts.Update(func(tx coa.KeyValueStore) error {
+			return (&Store{tx, s.keys}).reencrypt(key, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reencrypt encrypts the value of key again, unless it is missing or
+// already encrypted with the key currentId.
+func (s *Store) reencrypt(key []byte, currentId string) error {
+	data, err := s.kv.Get(key)
+	if err != nil || len(data) == 0 || keyId(data) == currentId {
+		return err
+	}
+	value, err := s.decrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return s.Put(key, value)
+}
+
+func keyId(data []byte) string {
+	n := int(data[0])
+	if len(data) < 1+n {
+		return ""
+	}
+	return string(data[1 : 1+n])
+}
+
+func (s *Store) store() *Store {
+	return s
+}
+
+type scanStore struct {
+	*Store
+}
+
+func (s scanStore) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	return s.kv.(coa.ScannableKeyValueStore).Scan(prefix, func(key []byte, data []byte) error {
+		if len(data) == 0 {
+			return fn(key, data)
+		}
+		value, err := s.decrypt(key, data)
+		if err != nil {
+			return err
+		}
+		return fn(key, value)
+	})
+}
+
+func (s scanStore) Delete(key []byte) error {
+	return s.kv.(coa.ScannableKeyValueStore).Delete(key)
+}
+
+type txStore struct {
+	*Store
+}
+
+func (s txStore) Update(fn func(tx coa.KeyValueStore) error) error {
+	return s.kv.(coa.TransactionalKeyValueStore).Update(func(tx coa.KeyValueStore) error {
+		return fn(wrap(&Store{tx, s.keys}))
+	})
+}
+
+type txScanStore struct {
+	scanStore
+}
+
+func (s txScanStore) Update(fn func(tx coa.KeyValueStore) error) error {
+	return txStore{s.Store}.Update(fn)
+}
@@ -0,0 +1,162 @@
+package cryptostore
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-accounting/coa"
+	"github.com/go-accounting/coa/boltstore"
+	"github.com/go-accounting/coa/storetest"
+)
+
+type mapStore struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+func (s *mapStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]byte(nil), s.m[string(key)]...), nil
+}
+
+func (s *mapStore) Put(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+var keys = Keys{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+
+func bolt(t *testing.T) *boltstore.Store {
+	s, err := boltstore.Open(filepath.Join(t.TempDir(), "coa.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("Plain", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) coa.KeyValueStore {
+			return New(&mapStore{m: map[string][]byte{}}, keys)
+		})
+	})
+	t.Run("Bolt", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) coa.KeyValueStore { return New(bolt(t), keys) })
+	})
+}
+
+func TestForwardsCapabilities(t *testing.T) {
+	if _, ok := New(&mapStore{}, keys).(coa.ScannableKeyValueStore); ok {
+		t.Error("Expected a plain store to stay plain")
+	}
+	s := New(bolt(t), keys)
+	if _, ok := s.(coa.ScannableKeyValueStore); !ok {
+		t.Error("Expected a scannable store")
+	}
+	if _, ok := s.(coa.TransactionalKeyValueStore); !ok {
+		t.Error("Expected a transactional store")
+	}
+}
+
+func TestCiphertext(t *testing.T) {
+	inner := &mapStore{m: map[string][]byte{}}
+	s := New(inner, keys)
+	r := coa.NewCoaRepository(s)
+	c, err := r.SaveChartOfAccounts(&coa.ChartOfAccounts{Name: "Confidential Client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range inner.m {
+		if bytes.Contains(v, []byte("Confidential")) {
+			t.Errorf("Expected %v to be encrypted", k)
+		}
+	}
+	inner.m["accounts/other"] = inner.m["charts-of-accounts"]
+	if _, err := s.Get([]byte("accounts/other")); err == nil {
+		t.Error("Expected a value moved to another key not to decrypt")
+	}
+	data := inner.m["charts-of-accounts"]
+	data[len(data)-1] ^= 1
+	if _, err := r.GetChartOfAccounts(c.Id); err == nil {
+		t.Error("Expected a tampered value not to decrypt")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	inner := bolt(t)
+	old := New(inner, keys)
+	if err := old.Put([]byte("accounts/c1"), []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	rotated := Keys{Current: "k2", Keys: map[string][]byte{"k1": keys.Keys["k1"], "k2": bytes.Repeat([]byte{2}, 16)}}
+	s := New(inner, rotated)
+	if err := s.Put([]byte("accounts/c2"), []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"accounts/c1": "v1", "accounts/c2": "v2"} {
+		if v, err := s.Get([]byte(key)); err != nil || string(v) != value {
+			t.Errorf("Expected %v but was %q, %v", value, v, err)
+		}
+	}
+	if err := Rotate(s, []byte("accounts/")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"accounts/c1", "accounts/c2"} {
+		data, err := inner.Get([]byte(key))
+		if err != nil || keyId(data) != "k2" {
+			t.Errorf("Expected %v to be encrypted with k2 but was %v, %v", key, keyId(data), err)
+		}
+	}
+	_, err := New(inner, Keys{Current: "k2", Keys: map[string][]byte{"k2": rotated.Keys["k2"]}}).Get([]byte("accounts/c1"))
+	if err != nil {
+		t.Errorf("Expected k1 to be no longer needed but was %v", err)
+	}
+	if _, err := old.Get([]byte("accounts/c1")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey but was %v", err)
+	}
+	if err := Rotate(&mapStore{}, nil); err == nil {
+		t.Error("Expected Rotate to fail for other stores")
+	}
+}
+
+// racingStore runs a write after each scan, as a writer running alongside
+// Rotate would.
+type racingStore struct {
+	*boltstore.Store
+	write func()
+}
+
+func (s racingStore) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	if err := s.Store.Scan(prefix, fn); err != nil {
+		return err
+	}
+	s.write()
+	return nil
+}
+
+func TestRotateAlongsideWriter(t *testing.T) {
+	inner := bolt(t)
+	if err := New(inner, keys).Put([]byte("accounts/c1"), []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	rotated := Keys{Current: "k2", Keys: map[string][]byte{"k1": keys.Keys["k1"], "k2": bytes.Repeat([]byte{2}, 16)}}
+	var s coa.KeyValueStore
+	s = New(racingStore{inner, func() {
+		if err := s.Put([]byte("accounts/c1"), []byte("v2")); err != nil {
+			t.Fatal(err)
+		}
+	}}, rotated)
+	if err := Rotate(s, []byte("accounts/")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Get([]byte("accounts/c1")); err != nil || string(v) != "v2" {
+		t.Errorf("Expected the concurrent write v2 but was %q, %v", v, err)
+	}
+}
@@ -0,0 +1,210 @@
+package coa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SchemaVersion is the version of the stored data written by this package.
+// Values are stored in an envelope:
+//
+//	0xc1 'c' 'o' 'a' <schema version> <codec> <body>
+//
+// 0xc1 is never used by MessagePack, so values written before envelopes were
+// introduced, which are bare msgp, are told apart and read as version 0.
+const SchemaVersion = 1
+
+const (
+	envelopeMagic      = "\xc1coa"
+	envelopeHeaderSize = len(envelopeMagic) + 2
+)
+
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+// Migration upgrades a stored value from one schema version to the next.
+// The value is given in its generic form, as maps, slices and scalars
+// decoded by the value's codec, and key tells which kind of value it is, e.g.
+// "charts-of-accounts" or "accounts/<coaid>". Fields of charts and accounts
+// are keyed by their Go names, like "Id" and "AsOf", whatever the codec;
+// JSON and CBOR values are renamed to and from that form around migrations.
+// Scalars keep the types the codec decodes them to.
+type Migration func(key string, value interface{}) (interface{}, error)
+
+// Migrations is a registry of migrations, indexed by the schema version
+// they upgrade from. Version 0 upgrades to 1 without changes unless a
+// migration is registered for it.
+type Migrations struct {
+	mu    sync.RWMutex
+	steps map[int]Migration
+}
+
+func NewMigrations() *Migrations {
+	return &Migrations{steps: map[int]Migration{}}
+}
+
+func (m *Migrations) Register(from int, fn Migration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps[from] = fn
+}
+
+func (m *Migrations) step(from int) (Migration, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fn, ok := m.steps[from]
+	return fn, ok
+}
+
+// DefaultMigrations is used by repositories created without WithMigrations.
+var DefaultMigrations = NewMigrations()
+
+func RegisterMigration(from int, fn Migration) {
+	DefaultMigrations.Register(from, fn)
+}
+
+func WithMigrations(m *Migrations) Option {
+	return func(r *CoaRepository) { r.migrations = m }
+}
+
+// WithRewriteOnRead makes the repository write back, in the current schema
+// version, the values it reads in an older one.
+func WithRewriteOnRead() Option {
+	return func(r *CoaRepository) { r.rewriteOnRead = true }
+}
+
+func seal(codec Codec, body []byte) []byte {
+	data := make([]byte, 0, envelopeHeaderSize+len(body))
+	data = append(data, envelopeMagic...)
+	data = append(data, SchemaVersion, codec.ID())
+	return append(data, body...)
+}
+
+// open returns the schema version, codec and body of a stored value.
+func open(data []byte) (version int, codec byte, body []byte) {
+	if len(data) < envelopeHeaderSize || string(data[:len(envelopeMagic)]) != envelopeMagic {
+		return 0, Msgp.ID(), data
+	}
+	return int(data[len(envelopeMagic)]), data[len(envelopeMagic)+1], data[envelopeHeaderSize:]
+}
+
+func outdated(data []byte) bool {
+	version, _, _ := open(data)
+	return len(data) > 0 && version < SchemaVersion
+}
+
+// upgrade returns the codec and body of a stored value in the current schema
+// version.
+func (r *CoaRepository) upgrade(key string, data []byte) (Codec, []byte, error) {
+	version, id, body := open(data)
+	if version > SchemaVersion {
+		return nil, nil, fmt.Errorf("%w: %v has version %v", ErrUnsupportedSchema, key, version)
+	}
+	codec, ok := r.codecFor(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown codec %v for %v", id, key)
+	}
+	var value interface{}
+	decoded := false
+	for ; version < SchemaVersion; version++ {
+		fn, ok := r.migrations.step(version)
+		if !ok && version == 0 {
+			continue
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: no migration from version %v for %v", ErrUnsupportedSchema, version, key)
+		}
+		if !decoded {
+			if err := codec.Unmarshal(body, &value); err != nil {
+				return nil, nil, err
+			}
+			if jsonNamed(codec) {
+				value = renameFields(value, goNames)
+			}
+			decoded = true
+		}
+		v, err := fn(key, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("migrating %v from version %v: %w", key, version, err)
+		}
+		value = v
+	}
+	if decoded {
+		if jsonNamed(codec) {
+			value = renameFields(value, jsonNames)
+		}
+		b, err := codec.Marshal(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = b
+	}
+	return codec, body, nil
+}
+
+// rewrite stores key again in the current schema version.
+func (r *CoaRepository) rewrite(key string) error {
+	data, err := r.store.Get([]byte(key))
+	if err != nil || !outdated(data) {
+		return err
+	}
+	codec, body, err := r.upgrade(key, data)
+	if err != nil {
+		return err
+	}
+	return r.store.Put([]byte(key), seal(codec, body))
+}
+
+// goNames maps the JSON names of the fields of charts and accounts, used by
+// the generic form of JSON and CBOR values, to their Go names; jsonNames
+// maps them back.
+var goNames, jsonNames = fieldNames(chartRecord{}, accountRecord{})
+
+func fieldNames(records ...interface{}) (map[string]string, map[string]string) {
+	toGo, toJSON := map[string]string{}, map[string]string{}
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				add(f.Type)
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				toGo[name], toJSON[f.Name] = f.Name, name
+			}
+		}
+	}
+	for _, r := range records {
+		add(reflect.TypeOf(r))
+	}
+	return toGo, toJSON
+}
+
+func jsonNamed(c Codec) bool {
+	return c.ID() == JSON.ID() || c.ID() == CBOR.ID()
+}
+
+// renameFields renames the keys of the maps in value, a record or a slice
+// of records in generic form.
+func renameFields(value interface{}, names map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if name, ok := names[k]; ok {
+				k = name
+			}
+			m[k] = e
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = renameFields(e, names)
+		}
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestReadsDataWithoutEnvelope(t *testing.T) {
+	s := store{}
+	legacy, err := ChartsOfAccounts{{Id: "c1", Name: "legacy"}}.MarshalMsg(nil)
+	check(t, err)
+	s["charts-of-accounts"] = legacy
+	r := NewCoaRepository(s)
+	coa, err := r.GetChartOfAccounts("c1")
+	check(t, err)
+	if coa == nil || coa.Name != "legacy" {
+		t.Fatalf("Expected the legacy coa but was %v", coa)
+	}
+	if string(s["charts-of-accounts"]) != string(legacy) {
+		t.Error("Expected the value to be left as is")
+	}
+	coa.Name = "saved"
+	_, err = r.SaveChartOfAccounts(coa)
+	check(t, err)
+	version, codec, _ := open(s["charts-of-accounts"])
+	if version != SchemaVersion || codec != Msgp.ID() {
+		t.Errorf("Expected version %v and msgp but was %v and %v", SchemaVersion, version, codec)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	asOf := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	legacy, err := msgp.AppendIntf(nil, []interface{}{
+		map[string]interface{}{"Id": "a1", "Number": "1", "Nome": "old name", "AsOf": asOf},
+	})
+	check(t, err)
+	m := NewMigrations()
+	m.Register(0, func(key string, value interface{}) (interface{}, error) {
+		if key != "accounts/c1" {
+			return nil, errors.New("unexpected key " + key)
+		}
+		for _, a := range value.([]interface{}) {
+			a := a.(map[string]interface{})
+			a["Name"] = a["Nome"]
+			delete(a, "Nome")
+		}
+		return value, nil
+	})
+	for _, s := range []KeyValueStore{store{}, scanStore{store{}}} {
+		for _, rewrite := range []bool{false, true} {
+			s.Put([]byte("accounts/c1"), legacy)
+			options := []Option{WithMigrations(m)}
+			if rewrite {
+				options = append(options, WithRewriteOnRead())
+			}
+			r := NewCoaRepository(s, options...)
+			accounts, err := r.AllAccounts("c1")
+			check(t, err)
+			if len(accounts) != 1 || accounts[0].Name != "old name" || !accounts[0].AsOf.Equal(asOf) {
+				t.Fatalf("Expected the migrated account but was %v", accounts)
+			}
+			data, err := s.Get([]byte("accounts/c1"))
+			check(t, err)
+			if outdated(data) == rewrite {
+				t.Errorf("Expected the value to be rewritten: %v", rewrite)
+			}
+			accounts, err = NewCoaRepository(s).AllAccounts("c1")
+			check(t, err)
+			if rewrite && (len(accounts) != 1 || accounts[0].Name != "old name") {
+				t.Errorf("Expected the rewritten account but was %v", accounts)
+			}
+		}
+	}
+}
+
+func TestRewriteScannedData(t *testing.T) {
+	s := store{}
+	legacy, err := (&ChartOfAccounts{Id: "c1", Name: "legacy"}).MarshalMsg(nil)
+	check(t, err)
+	s["charts-of-accounts/c1"] = legacy
+	r := NewCoaRepository(scanStore{s}, WithRewriteOnRead())
+	coas, err := r.AllChartsOfAccounts()
+	check(t, err)
+	if len(coas) != 1 || coas[0].Name != "legacy" {
+		t.Fatalf("Expected the legacy coa but was %v", coas)
+	}
+	if outdated(s["charts-of-accounts/c1"]) {
+		t.Error("Expected the scanned value to be rewritten")
+	}
+}
+
+func TestNewerSchemaVersion(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s)
+	_, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	s["charts-of-accounts"][len(envelopeMagic)] = SchemaVersion + 1
+	if _, err := r.AllChartsOfAccounts(); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("Expected ErrUnsupportedSchema but was %v", err)
+	}
+}
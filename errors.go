@@ -0,0 +1,129 @@
+package coa
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrEmptyCoaid              = errors.New("Invalid argument: coaid is empty")
+	ErrNilAccount              = errors.New("Invalid argument: account is nil")
+	ErrNilChartOfAccounts      = errors.New("Invalid argument: coa is nil")
+	ErrAccountNotFound         = errors.New("Account not found")
+	ErrChartOfAccountsNotFound = errors.New("Chart of accounts not found")
+	ErrChartOfAccountsRemoved  = errors.New("The chart of accounts is removed")
+	ErrAccountHasChildren      = errors.New("The account has children and cannot be removed")
+	ErrConflict                = errors.New("The record was changed by another user")
+	ErrNoMask                  = errors.New("The chart of accounts has no mask")
+	ErrNoFreeNumber            = errors.New("No free number")
+)
+
+func conflict(id string, version, stored int) error {
+	return fmt.Errorf("%w: %v (version %v, stored version %v)", ErrConflict, id, version, stored)
+}
+
+// ErrorCode identifies a validation rule. It is stable across releases and
+// can be matched with errors.Is against any *ValidationError.
+type ErrorCode string
+
+func (c ErrorCode) Error() string { return string(c) }
+
+const (
+	ErrNameRequired                      ErrorCode = "nameRequired"
+	ErrNumberRequired                    ErrorCode = "numberRequired"
+	ErrFinancialStatementRequired        ErrorCode = "financialStatementRequired"
+	ErrAmbiguousFinancialStatement       ErrorCode = "ambiguousFinancialStatement"
+	ErrNormalBalanceRequired             ErrorCode = "normalBalanceRequired"
+	ErrAmbiguousNormalBalance            ErrorCode = "ambiguousNormalBalance"
+	ErrMultipleIncomeStatementAttributes ErrorCode = "multipleIncomeStatementAttributes"
+	ErrDuplicateNumber                   ErrorCode = "duplicateNumber"
+	ErrParentNotFound                    ErrorCode = "parentNotFound"
+	ErrNumberPrefixMismatch              ErrorCode = "numberPrefixMismatch"
+	ErrInheritedPropertyMismatch         ErrorCode = "inheritedPropertyMismatch"
+	ErrCyclicParent                      ErrorCode = "cyclicParent"
+	ErrInvalidMask                       ErrorCode = "invalidMask"
+	ErrNumberMaskMismatch                ErrorCode = "numberMaskMismatch"
+	ErrNumberLevelMismatch               ErrorCode = "numberLevelMismatch"
+	ErrNegativePolicy                    ErrorCode = "negativePolicy"
+	ErrPostingLevelBeyondMaxDepth        ErrorCode = "postingLevelBeyondMaxDepth"
+	ErrMaxDepthExceeded                  ErrorCode = "maxDepthExceeded"
+	ErrShallowDetailAccount              ErrorCode = "shallowDetailAccount"
+	ErrTooFewTopLevelGroups              ErrorCode = "tooFewTopLevelGroups"
+)
+
+type ValidationError struct {
+	Code    ErrorCode
+	Field   string
+	Params  map[string]string
+	Message string
+}
+
+func newValidationError(code ErrorCode, field string, params ...string) *ValidationError {
+	e := &ValidationError{Code: code, Field: field}
+	if len(params) > 0 {
+		e.Params = map[string]string{}
+		for i := 0; i+1 < len(params); i += 2 {
+			e.Params[params[i]] = params[i+1]
+		}
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return English.Message(e)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Code }
+
+// ValidationErrors holds every rule violated by an account or chart of
+// accounts, in the order the rules are checked.
+type ValidationErrors []*ValidationError
+
+func (errs *ValidationErrors) add(code ErrorCode, field string, params ...string) {
+	*errs = append(*errs, newValidationError(code, field, params...))
+}
+
+func (errs ValidationErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (errs ValidationErrors) Error() string {
+	ss := make([]string, len(errs))
+	for i, e := range errs {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "; ")
+}
+
+func (errs ValidationErrors) Unwrap() []error {
+	result := make([]error, len(errs))
+	for i, e := range errs {
+		result[i] = e
+	}
+	return result
+}
+
+func (errs ValidationErrors) Field(field string) ValidationErrors {
+	var result ValidationErrors
+	for _, e := range errs {
+		if e.Field == field {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (errs ValidationErrors) ByField() map[string]ValidationErrors {
+	result := map[string]ValidationErrors{}
+	for _, e := range errs {
+		result[e.Field] = append(result[e.Field], e)
+	}
+	return result
+}
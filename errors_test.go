@@ -0,0 +1,93 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidationErrors(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	_, err = r.SaveChartOfAccounts(&ChartOfAccounts{})
+	if !errors.Is(err, ErrNameRequired) {
+		t.Errorf("Expected ErrNameRequired but was %v", err)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError but was %T", err)
+	}
+	if verr.Code != ErrNumberRequired || verr.Field != "number" {
+		t.Errorf("Expected ErrNumberRequired on number but was %v on %v", verr.Code, verr.Field)
+	}
+	if err.Error() != "The number must be informed" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	if !errors.Is(err, ErrDuplicateNumber) {
+		t.Errorf("Expected ErrDuplicateNumber but was %v", err)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: "x", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	if !errors.As(err, &verr) || verr.Code != ErrParentNotFound || verr.Params["parent"] != "x" {
+		t.Errorf("Expected ErrParentNotFound with parent x but was %v", err)
+	}
+	if err.Error() != "Parent not found: x" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"incomeStatement", "increaseOnDebit"}})
+	if !errors.As(err, &verr) || verr.Code != ErrInheritedPropertyMismatch || verr.Params["tag"] != "balanceSheet" {
+		t.Errorf("Expected ErrInheritedPropertyMismatch on balanceSheet but was %v", err)
+	}
+	if err.Error() != "The financial statement must be same as the parent" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if _, err := r.SaveAccount("", &Account{}); err != ErrEmptyCoaid {
+		t.Errorf("Expected ErrEmptyCoaid but was %v", err)
+	}
+	if _, err := r.SaveAccount(coa.Id, nil); err != ErrNilAccount {
+		t.Errorf("Expected ErrNilAccount but was %v", err)
+	}
+	if err := r.DeleteAccount(coa.Id, "x"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound but was %v", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a := &Account{Number: "1", Tags: []string{"incomeStatement", "operating", "deduction"}}
+	err = a.Validate(coa.Id, r)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors but was %T", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors but was %v", errs)
+	}
+	for _, code := range []ErrorCode{ErrNameRequired, ErrNormalBalanceRequired, ErrMultipleIncomeStatementAttributes} {
+		if !errors.Is(err, code) {
+			t.Errorf("Expected %v in %v", code, err)
+		}
+	}
+	byField := errs.ByField()
+	if len(byField["name"]) != 1 || len(byField["tags"]) != 2 {
+		t.Errorf("Unexpected errors by field %v", byField)
+	}
+	if len(errs.Field("number")) != 0 {
+		t.Errorf("Expected no errors on number but was %v", errs.Field("number"))
+	}
+	if msg := a.ValidationMessage(coa.Id, r); msg != "The name must be informed" {
+		t.Errorf("Expected only the first message but was %q", msg)
+	}
+	_, err = r.SaveAccount(coa.Id, a)
+	if !errors.Is(err, ErrNameRequired) || !errors.Is(err, ErrMultipleIncomeStatementAttributes) {
+		t.Errorf("Expected SaveAccount to report all errors but was %v", err)
+	}
+	if err := (&Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}}).Validate(coa.Id, r); err != nil {
+		t.Errorf("Expected nil but was %v", err)
+	}
+}
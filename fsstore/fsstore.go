@@ -0,0 +1,275 @@
+// Package fsstore is a coa.KeyValueStore keeping one file per key in a
+// directory, for single user deployments and command line tools.
+//
+// Every segment of a key but the last, split at "/", names a subdirectory,
+// so "accounts/c1/ids" is the file "ids" in "accounts%2F/c1%2F". A scan
+// therefore reads only the directories under its prefix.
+//
+// Files are written to a temporary file, synced and renamed over the old
+// one, so a crash leaves either the old or the new value. Only one Store at
+// a time, in any process, can open a directory.
+package fsstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var ErrLocked = errors.New("fsstore: directory is in use")
+
+// Store implements coa.ScannableKeyValueStore.
+type Store struct {
+	dir  string
+	lock *os.File
+}
+
+// Open opens the directory at dir, creating it if needed, and locks it
+// until Close.
+func Open(dir string) (*Store, error) {
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lock(filepath.Join(dir, ".lock"))
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir, lock}, nil
+}
+
+func (s *Store) Close() error {
+	return unlock(s.lock)
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *Store) Put(key []byte, value []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(name)
+	_, err = os.Stat(dir)
+	created := os.IsNotExist(err)
+	if created {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	f, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+	return s.syncDirs(dir, created)
+}
+
+func (s *Store) Delete(key []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// Scan reads the directory of the prefix and the subdirectories matching
+// it, leaving the rest of the store alone.
+func (s *Store) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	p := string(prefix)
+	i := strings.LastIndex(p, "/")
+	dir := s.dir
+	if i != -1 {
+		dir = filepath.Join(append([]string{s.dir}, dirNames(p[:i])...)...)
+	}
+	var keys []string
+	if err := collect(dir, p[:i+1], p[i+1:], &keys); err != nil {
+		return err
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := s.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			// deleted since the listing
+			continue
+		}
+		if err := fn([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect adds to keys those of the files under dir, holding the keys
+// starting with base, whose next segment starts with rest.
+func collect(dir string, base string, rest string, keys *[]string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if e.IsDir() {
+			if !strings.HasSuffix(name, "%2F") {
+				continue
+			}
+			segment, err := unescape(strings.TrimSuffix(name, "%2F"))
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(segment, rest) {
+				if err := collect(filepath.Join(dir, name), base+segment+"/", "", keys); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		segment := ""
+		if name != "%" {
+			if segment, err = unescape(name); err != nil {
+				return err
+			}
+		}
+		if strings.HasPrefix(segment, rest) {
+			*keys = append(*keys, base+segment)
+		}
+	}
+	return nil
+}
+
+// path returns the file of key. Directories are suffixed with "%2F", which
+// file names never end with, so a key can be a value and a prefix of others,
+// like "accounts/c1" and "accounts/c1/ids". A key ending with "/" is the
+// file "%", a name escape never produces.
+func (s *Store) path(key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("fsstore: empty key")
+	}
+	k := string(key)
+	i := strings.LastIndex(k, "/")
+	elems := []string{s.dir}
+	if i != -1 {
+		elems = append(elems, dirNames(k[:i])...)
+	}
+	file := escape(k[i+1:])
+	if file == "" {
+		file = "%"
+	}
+	return filepath.Join(append(elems, file)...), nil
+}
+
+func dirNames(segments string) []string {
+	names := strings.Split(segments, "/")
+	for i, segment := range names {
+		names[i] = escape(segment) + "%2F"
+	}
+	return names
+}
+
+// syncDirs makes a rename from the store's directory into dir durable,
+// along with the creation of dir and its parents when created.
+func (s *Store) syncDirs(dir string, created bool) error {
+	for {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+		if dir == s.dir {
+			return nil
+		}
+		if created {
+			dir = filepath.Dir(dir)
+		} else {
+			dir = s.dir
+		}
+	}
+}
+
+// syncDir makes a rename or removal in dir durable. Windows cannot sync
+// directories.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// escape turns a key into a file name: letters, digits, "-" and "_" are
+// kept and any other byte, "/" and "." included, becomes %XX. File names
+// starting with "." are therefore free for the store's own files.
+func escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func unescape(name string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			b.WriteByte(name[i])
+			continue
+		}
+		var c byte
+		if i+2 >= len(name) {
+			return "", fmt.Errorf("fsstore: bad file name %q", name)
+		}
+		if _, err := fmt.Sscanf(name[i+1:i+3], "%02X", &c); err != nil {
+			return "", fmt.Errorf("fsstore: bad file name %q", name)
+		}
+		b.WriteByte(c)
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,131 @@
+package fsstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-accounting/coa"
+	"github.com/go-accounting/coa/storetest"
+)
+
+func open(t *testing.T, dir string) *Store {
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) coa.KeyValueStore { return open(t, t.TempDir()) })
+}
+
+func TestEscape(t *testing.T) {
+	for key, expected := range map[string]string{
+		"charts-of-accounts":     "charts-of-accounts",
+		"accounts/c1":            "accounts%2Fc1",
+		"accounts/c1/number/1.1": "accounts%2Fc1%2Fnumber%2F1%2E1",
+		"..":                     "%2E%2E",
+		"%":                      "%25",
+	} {
+		if actual := escape(key); actual != expected {
+			t.Errorf("Expected %v for %v but was %v", expected, key, actual)
+		}
+		if actual, err := unescape(expected); err != nil || actual != key {
+			t.Errorf("Expected %v for %v but was %v, %v", key, expected, actual, err)
+		}
+	}
+}
+
+func TestPutLeavesOnlyTheValue(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	for _, v := range []string{"v1", "v2"} {
+		if err := s.Put([]byte("accounts/c1"), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := list(t, dir); len(names) != 2 || names[0] != ".lock" || names[1] != "accounts%2F" {
+		t.Errorf("Expected .lock and accounts%%2F but was %v", names)
+	}
+	if names := list(t, filepath.Join(dir, "accounts%2F")); len(names) != 1 || names[0] != "c1" {
+		t.Errorf("Expected c1 but was %v", names)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "accounts%2F", "c1"))
+	if err != nil || string(data) != "v2" {
+		t.Errorf("Expected v2 but was %q, %v", data, err)
+	}
+}
+
+func list(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestPath(t *testing.T) {
+	s := &Store{dir: "d"}
+	for key, expected := range map[string]string{
+		"charts-of-accounts":     "d/charts-of-accounts",
+		"accounts/c1":            "d/accounts%2F/c1",
+		"accounts/c1/number/1.1": "d/accounts%2F/c1%2F/number%2F/1%2E1",
+		"a//b/":                  "d/a%2F/%2F/b%2F/%",
+	} {
+		if actual, err := s.path([]byte(key)); err != nil || actual != filepath.FromSlash(expected) {
+			t.Errorf("Expected %v for %v but was %v, %v", expected, key, actual, err)
+		}
+	}
+}
+
+func TestScanReadsOnlyThePrefix(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	for _, key := range []string{"accounts/c1", "accounts/c1/ids", "accounts/c2", "accounts-x", "history/c1/a1"} {
+		if err := s.Put([]byte(key), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a file name Scan cannot read, outside the scanned directories
+	if err := os.WriteFile(filepath.Join(dir, "history%2F", "%ZZ"), []byte("v"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for prefix, expected := range map[string]string{
+		"accounts/":   "accounts/c1 accounts/c1/ids accounts/c2",
+		"accounts/c1": "accounts/c1 accounts/c1/ids",
+		"accounts":    "accounts-x accounts/c1 accounts/c1/ids accounts/c2",
+		"missing/":    "",
+	} {
+		var keys []string
+		err := s.Scan([]byte(prefix), func(key []byte, value []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+		if err != nil || strings.Join(keys, " ") != expected {
+			t.Errorf("Expected %v for %v but was %v, %v", expected, prefix, keys, err)
+		}
+	}
+	if err := s.Scan([]byte("history/"), func(key []byte, value []byte) error { return nil }); err == nil {
+		t.Error("Expected the bad file name under history/ to be read")
+	}
+}
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir)
+	if _, err := Open(dir); err != ErrLocked {
+		t.Errorf("Expected ErrLocked but was %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	open(t, dir)
+}
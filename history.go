@@ -0,0 +1,65 @@
+package coa
+
+import "fmt"
+
+// WithHistory makes the repository record every revision of the accounts
+// it writes, for AccountHistory. Stores able to scan keep one key per
+// revision; the others keep all revisions of an account under a single key,
+// rewritten on every write.
+func WithHistory() Option {
+	return func(r *CoaRepository) { r.history = true }
+}
+
+// AccountHistory returns every revision of an account written by a
+// repository created WithHistory, oldest first.
+func (r *CoaRepository) AccountHistory(coaid string, id string) (Accounts, error) {
+	defer r.locks.readAccounts(coaid)()
+	if coaid == "" {
+		return nil, ErrEmptyCoaid
+	}
+	key := "history/" + coaid + "/" + id
+	if !r.scannable() {
+		var result Accounts
+		err := r.get(key, &result)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	var result Accounts
+	err := r.scan(key+"/", func(key string, data []byte) error {
+		a := &Account{}
+		if err := r.decode(key, data, a); err != nil {
+			return err
+		}
+		result = append(result, a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *CoaRepository) recordHistory(coaid string, accounts ...*Account) error {
+	if !r.history {
+		return nil
+	}
+	for _, a := range accounts {
+		key := "history/" + coaid + "/" + a.Id
+		if r.scannable() {
+			if err := r.put(fmt.Sprintf("%v/%010d", key, a.Version), a); err != nil {
+				return err
+			}
+			continue
+		}
+		var revisions Accounts
+		if err := r.get(key, &revisions); err != nil {
+			return err
+		}
+		if err := r.put(key, append(revisions, a)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package coa
+
+import "strings"
+
+// Layout selects how a chart's accounts are laid out in the KeyValueStore.
+//
+// BlobLayout keeps every account of a chart in a single "accounts/<coaid>"
+// value, so each read decodes and each write rewrites the whole chart.
+//
+// PerAccountLayout stores each account under its own key, plus index keys
+// mapping numbers and parents to account ids:
+//
+//	accounts/<coaid>/ids                 ids in creation order
+//	accounts/<coaid>/account/<id>        the account
+//	accounts/<coaid>/number/<number>     id of the live account with number
+//	accounts/<coaid>/children/<parentid> ids of the live children of parent
+//
+// Positions returned by Indexes are creation order in both layouts, unless
+// the chart has NaturalOrdering.
+type Layout int
+
+const (
+	BlobLayout Layout = iota
+	PerAccountLayout
+)
+
+func WithLayout(l Layout) Option {
+	return func(r *CoaRepository) {
+		switch l {
+		case PerAccountLayout:
+			r.layout = perAccountLayout{}
+		default:
+			r.layout = blobLayout{}
+		}
+	}
+}
+
+type accountLayout interface {
+	// all returns every account, removed ones included.
+	all(r *CoaRepository, coaid string) (Accounts, error)
+	// get returns the accounts with the given ids, nil for missing ones.
+	get(r *CoaRepository, coaid string, ids ...string) (Accounts, error)
+	ids(r *CoaRepository, coaid string) ([]string, error)
+	byNumber(r *CoaRepository, coaid string, number string) (*Account, error)
+	byNumberPrefix(r *CoaRepository, coaid string, prefix string) (Accounts, error)
+	children(r *CoaRepository, coaid string, parent string) (Accounts, error)
+	save(r *CoaRepository, coaid string, accounts ...*Account) error
+}
+
+type blobLayout struct{}
+
+// accounts returns the chart's accounts. Shared ones come from the cache and
+// must be cloned before being handed out or modified.
+func (blobLayout) accounts(r *CoaRepository, coaid string) (accounts Accounts, shared bool, err error) {
+	if accounts, ok := r.cachedAccounts(coaid); ok {
+		return accounts, true, nil
+	}
+	err = r.get("accounts/"+coaid, &accounts)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.cache == nil {
+		return accounts, false, nil
+	}
+	r.cache.add(coaid, accounts)
+	return accounts, true, nil
+}
+
+func (l blobLayout) all(r *CoaRepository, coaid string) (Accounts, error) {
+	accounts, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		accounts = accounts.clone()
+	}
+	return accounts, nil
+}
+
+func (l blobLayout) get(r *CoaRepository, coaid string, ids ...string) (Accounts, error) {
+	accounts, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*Account, len(accounts))
+	for _, a := range accounts {
+		byId[a.Id] = a
+	}
+	result := make(Accounts, len(ids))
+	for i, id := range ids {
+		result[i] = byId[id]
+	}
+	if shared {
+		result = result.clone()
+	}
+	return result, nil
+}
+
+func (l blobLayout) ids(r *CoaRepository, coaid string) ([]string, error) {
+	accounts, _, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(accounts))
+	for i, a := range accounts {
+		result[i] = a.Id
+	}
+	return result, nil
+}
+
+func (l blobLayout) byNumber(r *CoaRepository, coaid string, number string) (*Account, error) {
+	accounts, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if a.Number == number && a.Removed.IsZero() {
+			if shared {
+				a = a.clone()
+			}
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l blobLayout) byNumberPrefix(r *CoaRepository, coaid string, prefix string) (Accounts, error) {
+	accounts, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	result := accounts.withNumberPrefix(prefix)
+	if shared {
+		result = result.clone()
+	}
+	return result, nil
+}
+
+func (l blobLayout) children(r *CoaRepository, coaid string, parent string) (Accounts, error) {
+	accounts, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	var result Accounts
+	for _, a := range accounts {
+		if a.Parent == parent && a.Removed.IsZero() {
+			result = append(result, a)
+		}
+	}
+	if shared {
+		result = result.clone()
+	}
+	return result, nil
+}
+
+func (l blobLayout) save(r *CoaRepository, coaid string, accounts ...*Account) error {
+	stored, shared, err := l.accounts(r, coaid)
+	if err != nil {
+		return err
+	}
+	if shared {
+		// keep the shared accounts, but not the shared slice
+		stored = append(Accounts(nil), stored...)
+	}
+	for _, account := range accounts {
+		if r.cache != nil {
+			account = account.clone()
+		}
+		found := false
+		for i, a := range stored {
+			if a.Id == account.Id {
+				stored[i] = account
+				found = true
+				break
+			}
+		}
+		if !found {
+			stored = append(stored, account)
+		}
+	}
+	if err := r.put("accounts/"+coaid, stored); err != nil {
+		return err
+	}
+	r.cacheWritten(coaid, stored)
+	return nil
+}
+
+type perAccountLayout struct{}
+
+func (perAccountLayout) key(coaid string, kind string, name string) string {
+	return "accounts/" + coaid + "/" + kind + "/" + name
+}
+
+func (l perAccountLayout) all(r *CoaRepository, coaid string) (Accounts, error) {
+	if r.scannable() {
+		var result Accounts
+		err := r.scan(l.key(coaid, "account", ""), func(key string, data []byte) error {
+			a := &Account{}
+			if err := r.decode(key, data, a); err != nil {
+				return err
+			}
+			result = append(result, a)
+			return nil
+		})
+		return result, err
+	}
+	ids, err := l.ids(r, coaid)
+	if err != nil {
+		return nil, err
+	}
+	return l.get(r, coaid, ids...)
+}
+
+func (l perAccountLayout) get(r *CoaRepository, coaid string, ids ...string) (Accounts, error) {
+	result := make(Accounts, len(ids))
+	for i, id := range ids {
+		a := &Account{}
+		err := r.get(l.key(coaid, "account", id), a)
+		if err != nil {
+			return nil, err
+		}
+		if a.Id != "" {
+			result[i] = a
+		}
+	}
+	return result, nil
+}
+
+func (l perAccountLayout) ids(r *CoaRepository, coaid string) ([]string, error) {
+	var ids idList
+	err := r.get("accounts/"+coaid+"/ids", &ids)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (l perAccountLayout) byNumber(r *CoaRepository, coaid string, number string) (*Account, error) {
+	var ids idList
+	err := r.get(l.key(coaid, "number", number), &ids)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	accounts, err := l.get(r, coaid, ids[0])
+	if err != nil {
+		return nil, err
+	}
+	return accounts[0], nil
+}
+
+func (l perAccountLayout) byNumberPrefix(r *CoaRepository, coaid string, prefix string) (Accounts, error) {
+	if !r.scannable() {
+		accounts, err := l.all(r, coaid)
+		if err != nil {
+			return nil, err
+		}
+		return accounts.withNumberPrefix(prefix), nil
+	}
+	var ids idList
+	err := r.scan(l.key(coaid, "number", prefix), func(key string, data []byte) error {
+		var each idList
+		if err := r.decode(key, data, &each); err != nil {
+			return err
+		}
+		ids = append(ids, each...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l.get(r, coaid, ids...)
+}
+
+func (l perAccountLayout) children(r *CoaRepository, coaid string, parent string) (Accounts, error) {
+	var ids idList
+	err := r.get(l.key(coaid, "children", parent), &ids)
+	if err != nil {
+		return nil, err
+	}
+	return l.get(r, coaid, ids...)
+}
+
+func (l perAccountLayout) save(r *CoaRepository, coaid string, accounts ...*Account) error {
+	for _, account := range accounts {
+		stored, err := l.get(r, coaid, account.Id)
+		if err != nil {
+			return err
+		}
+		old := stored[0]
+		if err := r.put(l.key(coaid, "account", account.Id), account); err != nil {
+			return err
+		}
+		if old == nil {
+			err = l.update(r, "accounts/"+coaid+"/ids", func(ids idList) idList { return append(ids, account.Id) })
+			if err != nil {
+				return err
+			}
+		}
+		wasLive := old != nil && old.Removed.IsZero()
+		isLive := account.Removed.IsZero()
+		if wasLive && isLive && old.Number == account.Number && old.Parent == account.Parent {
+			continue
+		}
+		if wasLive {
+			err = l.update(r, l.key(coaid, "number", old.Number), func(ids idList) idList { return ids.without(account.Id) })
+			if err != nil {
+				return err
+			}
+			err = l.update(r, l.key(coaid, "children", old.Parent), func(ids idList) idList { return ids.without(account.Id) })
+			if err != nil {
+				return err
+			}
+		}
+		if isLive {
+			err = l.update(r, l.key(coaid, "number", account.Number), func(idList) idList { return idList{account.Id} })
+			if err != nil {
+				return err
+			}
+			err = l.update(r, l.key(coaid, "children", account.Parent), func(ids idList) idList {
+				return append(ids.without(account.Id), account.Id)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (perAccountLayout) update(r *CoaRepository, key string, fn func(idList) idList) error {
+	var ids idList
+	err := r.get(key, &ids)
+	if err != nil {
+		return err
+	}
+	ids = fn(ids)
+	if len(ids) == 0 {
+		return r.delete(key)
+	}
+	return r.put(key, ids)
+}
+
+func (aa Accounts) withNumberPrefix(prefix string) Accounts {
+	var result Accounts
+	for _, a := range aa {
+		if strings.HasPrefix(a.Number, prefix) && a.Removed.IsZero() {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func (ids idList) without(id string) idList {
+	result := make(idList, 0, len(ids))
+	for _, each := range ids {
+		if each != id {
+			result = append(result, each)
+		}
+	}
+	return result
+}
+
+// MigrateToPerAccountLayout moves every chart still stored in BlobLayout to
+// PerAccountLayout, keeping the creation order of the accounts. Charts
+// already migrated are left alone. Afterwards the repository must be created
+// with WithLayout(PerAccountLayout).
+func (r *CoaRepository) MigrateToPerAccountLayout() error {
+	unlock := r.locks.readCharts()
+	coas, err := r.allChartsOfAccounts(true)
+	unlock()
+	if err != nil {
+		return err
+	}
+	for _, coa := range coas {
+		if err := r.migrateToPerAccountLayout(coa.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CoaRepository) migrateToPerAccountLayout(coaid string) error {
+	defer r.locks.writeAccounts(coaid, false)()
+	return r.atomically(func(r *CoaRepository) error {
+		accounts, err := blobLayout{}.all(r, coaid)
+		if err != nil || len(accounts) == 0 {
+			return err
+		}
+		if err := (perAccountLayout{}).save(r, coaid, accounts...); err != nil {
+			return err
+		}
+		if err := r.put("accounts/"+coaid, Accounts{}); err != nil {
+			return err
+		}
+		r.cacheWritten(coaid, Accounts{})
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package coa
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPerAccountLayout(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s, WithLayout(PerAccountLayout))
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a2, err := r.SaveAccount(coa.Id, &Account{Number: "2", Name: "a2", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a11, err := r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	if _, ok := s["accounts/"+coa.Id]; ok {
+		t.Error("Expected no blob in the per account layout")
+	}
+	accounts, err := r.AllAccounts(coa.Id)
+	check(t, err)
+	if len(accounts) != 3 || accounts[0].Number != "1" || accounts[1].Number != "11" || accounts[2].Number != "2" {
+		t.Fatalf("Expected 1 11 2 but was %v", accounts)
+	}
+	if !accounts[0].Tags.Contains("summary") {
+		t.Errorf("Expected a1 to be summary but tags were %v", accounts[0].Tags)
+	}
+	idx, err := r.Indexes(coa.Id, []string{a1.Id, a11.Id, a2.Id, "x"}, nil)
+	check(t, err)
+	if idx[0] != 1 || idx[1] != 2 || idx[2] != 0 || idx[3] != -1 {
+		t.Errorf("Expected 1 2 0 -1 but was %v", idx)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "dup", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	if !errors.Is(err, ErrDuplicateNumber) {
+		t.Errorf("Expected ErrDuplicateNumber but was %v", err)
+	}
+	if err := r.DeleteAccount(coa.Id, a1.Id); err != ErrAccountHasChildren {
+		t.Errorf("Expected ErrAccountHasChildren but was %v", err)
+	}
+	check(t, r.DeleteAccount(coa.Id, a11.Id))
+	a, err := r.GetAccount(coa.Id, a1.Id)
+	check(t, err)
+	if !a.Tags.Contains("detail") || a.Tags.Contains("summary") {
+		t.Errorf("Expected a1 to be detail again but tags were %v", a.Tags)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	accounts, err = r.AllAccountsIncludingRemoved(coa.Id)
+	check(t, err)
+	if len(accounts) != 4 {
+		t.Errorf("Expected 4 accounts but was %v", len(accounts))
+	}
+}
+
+func TestMigrateToPerAccountLayout(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s)
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	var ids []string
+	for _, n := range []string{"3", "1", "2"} {
+		a, err := r.SaveAccount(coa.Id, &Account{Number: n, Name: n, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		ids = append(ids, a.Id)
+	}
+	check(t, r.DeleteAccount(coa.Id, ids[2]))
+	before, err := r.IndexesIncludingRemoved(coa.Id, ids, nil)
+	check(t, err)
+	check(t, r.MigrateToPerAccountLayout())
+	check(t, r.MigrateToPerAccountLayout())
+	r = NewCoaRepository(s, WithLayout(PerAccountLayout))
+	after, err := r.IndexesIncludingRemoved(coa.Id, ids, nil)
+	check(t, err)
+	if fmt.Sprint(before) != fmt.Sprint(after) {
+		t.Errorf("Expected indexes %v but was %v", before, after)
+	}
+	accounts, err := r.AllAccounts(coa.Id)
+	check(t, err)
+	if len(accounts) != 2 || accounts[0].Number != "1" || accounts[1].Number != "3" {
+		t.Errorf("Expected 1 3 but was %v", accounts)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "2", Name: "2", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+}
+
+func benchmarkRepository(b *testing.B, n int, options ...Option) (*CoaRepository, string, Accounts) {
+	r := NewCoaRepository(store{}, options...)
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var accounts Accounts
+	for i := 0; i < n; i++ {
+		a := &Account{Number: fmt.Sprintf("%05d", i), Name: "account", Tags: []string{"balanceSheet", "increaseOnDebit"}}
+		if i%10 != 0 {
+			a.Parent = accounts[i-i%10].Id
+			a.Number = accounts[i-i%10].Number + a.Number
+		}
+		if _, err := r.SaveAccount(coa.Id, a); err != nil {
+			b.Fatal(err)
+		}
+		accounts = append(accounts, a)
+	}
+	accounts, err = r.AllAccounts(coa.Id)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return r, coa.Id, accounts
+}
+
+func BenchmarkLayouts(b *testing.B) {
+	const n = 1000
+	for name, layout := range map[string]Layout{"Blob": BlobLayout, "PerAccount": PerAccountLayout} {
+		r, coaid, accounts := benchmarkRepository(b, n, WithLayout(layout))
+		b.Run(name+"/GetAccount", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := r.GetAccount(coaid, accounts[i%n].Id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/Indexes", func(b *testing.B) {
+			ids := []string{accounts[1].Id, accounts[n/2].Id, accounts[n-1].Id}
+			for i := 0; i < b.N; i++ {
+				if _, err := r.Indexes(coaid, ids, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/UpdateAccount", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				a := accounts[i%n]
+				a.Name = fmt.Sprint("account", i)
+				if _, err := r.SaveAccount(coaid, a); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		created := 0
+		b.Run(name+"/CreateAccount", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				created++
+				a := &Account{Number: fmt.Sprint("9", created), Name: "new", Tags: []string{"balanceSheet", "increaseOnDebit"}}
+				if _, err := r.SaveAccount(coaid, a); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
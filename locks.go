@@ -0,0 +1,85 @@
+package coa
+
+import "sync"
+
+// locks serializes writers per chart so that the read-modify-write of
+// "accounts/<coaid>" never loses an update, while different charts proceed
+// independently. The "charts-of-accounts" key has its own lock, always taken
+// after a chart's lock.
+type locks struct {
+	mu       sync.Mutex
+	charts   sync.RWMutex
+	accounts map[string]*chartLock
+}
+
+// chartLock is a chart's lock, counting the holders and waiters so that it
+// is dropped once unused and the map doesn't grow with every coaid seen.
+type chartLock struct {
+	sync.RWMutex
+	refs int
+}
+
+func newLocks() *locks {
+	return &locks{accounts: map[string]*chartLock{}}
+}
+
+func (l *locks) chart(coaid string) *chartLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, ok := l.accounts[coaid]
+	if !ok {
+		m = &chartLock{}
+		l.accounts[coaid] = m
+	}
+	m.refs++
+	return m
+}
+
+func (l *locks) release(coaid string, m *chartLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m.refs--
+	if m.refs == 0 {
+		delete(l.accounts, coaid)
+	}
+}
+
+func (l *locks) readCharts() func() {
+	l.charts.RLock()
+	return l.charts.RUnlock
+}
+
+func (l *locks) writeCharts() func() {
+	l.charts.Lock()
+	return l.charts.Unlock
+}
+
+// readAccounts locks a chart's accounts for reading, and the charts of
+// accounts too, as reads depend on the chart's settings.
+func (l *locks) readAccounts(coaid string) func() {
+	m := l.chart(coaid)
+	m.RLock()
+	unlock := l.readCharts()
+	return func() {
+		unlock()
+		m.RUnlock()
+		l.release(coaid, m)
+	}
+}
+
+// writeAccounts locks a chart's accounts for writing, and the charts of
+// accounts for writing too when the operation may update its chart.
+func (l *locks) writeAccounts(coaid string, writeCharts bool) func() {
+	m := l.chart(coaid)
+	m.Lock()
+	lockCharts := l.readCharts
+	if writeCharts {
+		lockCharts = l.writeCharts
+	}
+	unlock := lockCharts()
+	return func() {
+		unlock()
+		m.Unlock()
+		l.release(coaid, m)
+	}
+}
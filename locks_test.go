@@ -0,0 +1,156 @@
+package coa
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncStore struct {
+	sync.Mutex
+	store
+}
+
+// Get yields after reading to widen the window between the read and the
+// write of a read-modify-write, even on a single CPU.
+func (s *syncStore) Get(key []byte) ([]byte, error) {
+	defer runtime.Gosched()
+	s.Lock()
+	defer s.Unlock()
+	return s.store.Get(key)
+}
+
+func (s *syncStore) Put(key []byte, value []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.store.Put(key, value)
+}
+
+func TestConcurrentSaveAccount(t *testing.T) {
+	// the cache holds fewer accounts than the charts, so charts get evicted
+	for name, options := range map[string][]Option{"uncached": nil, "cached": {WithCache(100)}} {
+		r := NewCoaRepository(&syncStore{store: store{}}, options...)
+		var coas []*ChartOfAccounts
+		for i := 0; i < 3; i++ {
+			coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: fmt.Sprint("coa", i)})
+			check(t, err)
+			coas = append(coas, coa)
+		}
+		parents := map[string]*Account{}
+		for _, coa := range coas {
+			a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			parents[coa.Id] = a
+		}
+		const n = 50
+		var wg sync.WaitGroup
+		errs := make(chan error, n*len(coas)*2+1)
+		for _, coa := range coas {
+			for i := 0; i < n; i++ {
+				wg.Add(2)
+				go func(coaid string, i int) {
+					defer wg.Done()
+					_, err := r.SaveAccount(coaid, &Account{Number: fmt.Sprint("1.", i), Name: "a",
+						Parent: parents[coaid].Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+					errs <- err
+				}(coa.Id, i)
+				go func(coaid string) {
+					defer wg.Done()
+					_, err := r.AllAccounts(coaid)
+					errs <- err
+				}(coa.Id)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			coa, err := r.GetChartOfAccounts(coas[0].Id)
+			if err == nil {
+				coa.Name = "renamed"
+				_, err = r.SaveChartOfAccounts(coa)
+			}
+			errs <- err
+		}()
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			check(t, err)
+		}
+		for _, coa := range coas {
+			accounts, err := r.AllAccounts(coa.Id)
+			check(t, err)
+			if len(accounts) != n+1 {
+				t.Errorf("%v: Expected %v accounts but was %v", name, n+1, len(accounts))
+			}
+			if !accounts[0].Tags.Contains("summary") {
+				t.Errorf("%v: Expected %v to be a summary account", name, accounts[0])
+			}
+		}
+	}
+}
+
+func TestChartLocksAreReleased(t *testing.T) {
+	r := NewCoaRepository(&syncStore{store: store{}})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			r.AllAccounts(fmt.Sprint("unknown", i))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			r.SaveAccount(coa.Id, &Account{Number: fmt.Sprint(i), Name: "a", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		}(i)
+	}
+	wg.Wait()
+	if n := len(r.locks.accounts); n != 0 {
+		t.Errorf("Expected no chart locks left but was %v", n)
+	}
+}
+
+func TestDeleteAccountSharesChartsLock(t *testing.T) {
+	r := NewCoaRepository(&syncStore{store: store{}})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	// a reader of another chart holds the charts lock
+	defer r.locks.readAccounts("other")()
+	done := make(chan error, 1)
+	go func() { done <- r.DeleteAccount(coa.Id, a.Id) }()
+	select {
+	case err := <-done:
+		check(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Expected DeleteAccount not to wait for readers of other charts")
+	}
+}
+
+func TestValidateConcurrentWithSaveAccount(t *testing.T) {
+	r := NewCoaRepository(&syncStore{store: store{}}, WithCache(100))
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := r.SaveAccount(coa.Id, &Account{Number: fmt.Sprint(i), Name: "a", Tags: []string{"balanceSheet", "increaseOnDebit"}}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			a := &Account{Number: fmt.Sprint("x", i), Name: "a", Tags: []string{"balanceSheet", "increaseOnDebit"}}
+			if err := a.Validate(coa.Id, r); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
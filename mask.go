@@ -0,0 +1,137 @@
+package coa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mask is a chart's numbering mask, like "9.9.99.999": each run of 9s is a
+// level taking exactly that many digits, and the characters between runs
+// are the separators between levels.
+type mask string
+
+type maskLevel struct {
+	separator string
+	width     int
+}
+
+// levels returns nil for an invalid mask.
+func (m mask) levels() []maskLevel {
+	var levels []maskLevel
+	separator, width := "", 0
+	for _, c := range string(m) {
+		if c == '9' {
+			width++
+			continue
+		}
+		if width == 0 {
+			return nil
+		}
+		levels = append(levels, maskLevel{separator, width})
+		separator, width = string(c), 0
+	}
+	if width == 0 {
+		return nil
+	}
+	return append(levels, maskLevel{separator, width})
+}
+
+// level returns the level of number, from 1, or 0 if it does not follow the
+// mask.
+func (m mask) level(number string) int {
+	for i, l := range m.levels() {
+		if i > 0 {
+			if number == "" {
+				return i
+			}
+			if !strings.HasPrefix(number, l.separator) {
+				return 0
+			}
+			number = number[len(l.separator):]
+		}
+		if len(number) < l.width || !digits(number[:l.width]) {
+			return 0
+		}
+		number = number[l.width:]
+		if number == "" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NextNumber returns the first number after the highest one among taken
+// for a child of parentNumber, or of the chart when parentNumber is empty,
+// following the chart's mask. When the highest one is the last the level
+// holds, the first gap is returned instead.
+func (coa *ChartOfAccounts) NextNumber(parentNumber string, taken []string) (string, error) {
+	m := mask(coa.Mask)
+	levels := m.levels()
+	if levels == nil {
+		return "", ErrNoMask
+	}
+	parentLevel := 0
+	if parentNumber != "" {
+		parentLevel = m.level(parentNumber)
+		if parentLevel == 0 {
+			return "", fmt.Errorf("%w: %v does not follow %v", ErrNoFreeNumber, parentNumber, coa.Mask)
+		}
+	}
+	if parentLevel == len(levels) {
+		return "", fmt.Errorf("%w: %v is at the last level of %v", ErrNoFreeNumber, parentNumber, coa.Mask)
+	}
+	l := levels[parentLevel]
+	prefix := parentNumber + l.separator
+	used := map[int]bool{}
+	highest := 0
+	for _, number := range taken {
+		if !strings.HasPrefix(number, prefix) || m.level(number) != parentLevel+1 {
+			continue
+		}
+		n, _ := strconv.Atoi(number[len(prefix):])
+		used[n] = true
+		if n > highest {
+			highest = n
+		}
+	}
+	last, _ := strconv.Atoi(strings.Repeat("9", l.width))
+	next := highest + 1
+	if next > last {
+		for next = 1; next <= last && used[next]; next++ {
+		}
+		if next > last {
+			return "", fmt.Errorf("%w under %v", ErrNoFreeNumber, parentNumber)
+		}
+	}
+	return fmt.Sprintf("%v%0*d", prefix, l.width, next), nil
+}
+
+// checkMask reports the ways number breaks the chart's mask, if it has one.
+func (coa *ChartOfAccounts) checkMask(errs *ValidationErrors, number string, parentNumber string) {
+	if coa == nil || coa.Mask == "" || number == "" {
+		return
+	}
+	m := mask(coa.Mask)
+	level := m.level(number)
+	if level == 0 {
+		errs.add(ErrNumberMaskMismatch, "number", "number", number, "mask", coa.Mask)
+		return
+	}
+	parentLevel := 0
+	if parentNumber != "" {
+		parentLevel = m.level(parentNumber)
+	}
+	if (parentNumber == "" || parentLevel != 0) && level != parentLevel+1 {
+		errs.add(ErrNumberLevelMismatch, "number", "number", number, "parentNumber", parentNumber)
+	}
+}
@@ -0,0 +1,116 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaskLevel(t *testing.T) {
+	m := mask("9.9.99.999")
+	for number, level := range map[string]int{
+		"1": 1, "1.2": 2, "1.2.03": 3, "1.2.03.004": 4,
+		"": 0, "12": 0, "1.2.3": 0, "1-2": 0, "1.": 0, "1.2.03.004.5": 0, "a": 0,
+	} {
+		if actual := m.level(number); actual != level {
+			t.Errorf("Expected level %v for %q but was %v", level, number, actual)
+		}
+	}
+	for _, invalid := range []string{".9", "9.", "9..9", "x"} {
+		if mask(invalid).levels() != nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestNextNumber(t *testing.T) {
+	coa := &ChartOfAccounts{Mask: "9.9.99"}
+	taken := []string{"1", "2", "1.1", "1.2", "1.2.01", "1.2.07", "1.2.3"}
+	for parent, expected := range map[string]string{"": "3", "1": "1.3", "1.2": "1.2.08", "1.1": "1.1.01"} {
+		actual, err := coa.NextNumber(parent, taken)
+		check(t, err)
+		if actual != expected {
+			t.Errorf("Expected %v under %q but was %v", expected, parent, actual)
+		}
+	}
+	if n, err := coa.NextNumber("1", append(taken, "1.9")); err != nil || n != "1.3" {
+		t.Errorf("Expected the first gap 1.3 but was %v, %v", n, err)
+	}
+	full := []string{"1.1", "1.2", "1.3", "1.4", "1.5", "1.6", "1.7", "1.8", "1.9"}
+	if _, err := coa.NextNumber("1", full); !errors.Is(err, ErrNoFreeNumber) {
+		t.Errorf("Expected ErrNoFreeNumber but was %v", err)
+	}
+	if _, err := coa.NextNumber("1.2.01", taken); !errors.Is(err, ErrNoFreeNumber) {
+		t.Errorf("Expected ErrNoFreeNumber at the last level but was %v", err)
+	}
+	if _, err := (&ChartOfAccounts{}).NextNumber("", nil); err != ErrNoMask {
+		t.Errorf("Expected ErrNoMask but was %v", err)
+	}
+}
+
+func TestSaveAccountEnforcesMask(t *testing.T) {
+	r := NewCoaRepository(store{})
+	if _, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", Mask: "9..9"}); !errors.Is(err, ErrInvalidMask) {
+		t.Errorf("Expected ErrInvalidMask but was %v", err)
+	}
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", Mask: "9.9.99"})
+	check(t, err)
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	if _, err := r.SaveAccount(coa.Id, &Account{Number: "12", Name: "a", Tags: tags}); !errors.Is(err, ErrNumberMaskMismatch) {
+		t.Errorf("Expected ErrNumberMaskMismatch but was %v", err)
+	}
+	if _, err := r.SaveAccount(coa.Id, &Account{Number: "1.2", Name: "a", Tags: tags}); !errors.Is(err, ErrNumberLevelMismatch) {
+		t.Errorf("Expected ErrNumberLevelMismatch but was %v", err)
+	}
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: tags})
+	check(t, err)
+	for number, code := range map[string]ErrorCode{"1.2.01": ErrNumberLevelMismatch, "1.02": ErrNumberMaskMismatch} {
+		if _, err := r.SaveAccount(coa.Id, &Account{Number: number, Name: "a", Parent: a1.Id, Tags: tags}); !errors.Is(err, code) {
+			t.Errorf("Expected %v for %v but was %v", code, number, err)
+		}
+	}
+	a12, err := r.SaveAccount(coa.Id, &Account{Number: "1.2", Name: "a12", Parent: a1.Id, Tags: tags})
+	check(t, err)
+	if _, err := r.MoveAccount(coa.Id, a12.Id, "", "2.2"); !errors.Is(err, ErrNumberLevelMismatch) {
+		t.Errorf("Expected ErrNumberLevelMismatch but was %v", err)
+	}
+	if _, err := r.RenumberAccountsByMapping(coa.Id, map[string]string{"1.2": "1.02"}); !errors.Is(err, ErrNumberMaskMismatch) {
+		t.Errorf("Expected ErrNumberMaskMismatch but was %v", err)
+	}
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "1.2.01", Name: "a1201", Parent: a12.Id, Tags: tags})
+	check(t, err)
+	if _, err := r.MoveAccount(coa.Id, a12.Id, "", "2"); !errors.Is(err, ErrNumberMaskMismatch) {
+		t.Errorf("Expected ErrNumberMaskMismatch for the renumbered child but was %v", err)
+	}
+	r = NewCoaRepository(store{}, WithLocale("pt-BR"))
+	coa, err = r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", Mask: "9.99"})
+	check(t, err)
+	_, err = r.SaveAccount(coa.Id, &Account{Number: "1.1", Name: "a", Tags: tags})
+	if err == nil || err.Error() != "O número deve seguir a máscara 9.99" {
+		t.Errorf("Expected the message in Portuguese but was %v", err)
+	}
+}
+
+func TestMaskSetOnExistingChart(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: tags})
+	check(t, err)
+	coa.Mask = "99.99"
+	coa, err = r.SaveChartOfAccounts(coa)
+	check(t, err)
+	a1.Name = "renamed"
+	_, err = r.SaveAccount(coa.Id, a1)
+	check(t, err)
+	if _, err := r.SaveAccount(coa.Id, &Account{Number: "2", Name: "a2", Tags: tags}); !errors.Is(err, ErrNumberMaskMismatch) {
+		t.Errorf("Expected ErrNumberMaskMismatch for a new account but was %v", err)
+	}
+	var errs ValidationErrors
+	if err := r.ValidateChart(coa.Id); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != ErrNumberMaskMismatch {
+		t.Errorf("Expected ValidateChart to report 1 but was %v", err)
+	}
+	_, err = r.RenumberAccountsByMapping(coa.Id, map[string]string{"1": "01"})
+	check(t, err)
+	check(t, r.ValidateChart(coa.Id))
+}
@@ -0,0 +1,168 @@
+package coa
+
+import (
+	"strings"
+	"sync"
+)
+
+// Catalog turns a validation error into a message for the user.
+type Catalog interface {
+	Message(e *ValidationError) string
+}
+
+// MessageCatalog is a Catalog built from message templates. Placeholders
+// like {parent} are replaced by the error's params, and params found in
+// Terms (e.g. "financialStatement") are translated before being spliced in.
+// Codes missing from Messages fall back to English.
+type MessageCatalog struct {
+	Messages map[ErrorCode]string
+	Terms    map[string]string
+}
+
+func (c *MessageCatalog) Message(e *ValidationError) string {
+	msg, ok := c.Messages[e.Code]
+	if !ok {
+		if c == English {
+			return string(e.Code)
+		}
+		return English.Message(e)
+	}
+	for k, v := range e.Params {
+		if term, ok := c.Terms[v]; ok {
+			v = term
+		}
+		msg = strings.Replace(msg, "{"+k+"}", v, -1)
+	}
+	return msg
+}
+
+var English = &MessageCatalog{
+	Messages: map[ErrorCode]string{
+		ErrNameRequired:                      "The name must be informed",
+		ErrNumberRequired:                    "The number must be informed",
+		ErrFinancialStatementRequired:        "The financial statement must be informed",
+		ErrAmbiguousFinancialStatement:       "The statement must be either balance sheet or income statement",
+		ErrNormalBalanceRequired:             "The normal balance must be informed",
+		ErrAmbiguousNormalBalance:            "The normal balance must be either debit or credit",
+		ErrMultipleIncomeStatementAttributes: "Only one income statement attribute is allowed",
+		ErrDuplicateNumber:                   "An account with this number already exists",
+		ErrParentNotFound:                    "Parent not found: {parent}",
+		ErrNumberPrefixMismatch:              "The number must start with parent's number",
+		ErrInheritedPropertyMismatch:         "The {property} must be same as the parent",
+		ErrCyclicParent:                      "An account cannot be moved under itself or its descendants",
+		ErrInvalidMask:                       "The mask must be made of 9s and single separators, like 9.9.99",
+		ErrNumberMaskMismatch:                "The number must follow the mask {mask}",
+		ErrNumberLevelMismatch:               "The number must be one level below the parent's number",
+		ErrNegativePolicy:                    "The {policy} must not be negative",
+		ErrPostingLevelBeyondMaxDepth:        "The minimum posting level must not exceed the maximum depth",
+		ErrMaxDepthExceeded:                  "The account must not be deeper than level {maxDepth}",
+		ErrShallowDetailAccount:              "Detail account {number} must be at level {minPostingLevel} or deeper",
+		ErrTooFewTopLevelGroups:              "The chart must have at least {minTopLevelGroups} top-level groups",
+	},
+	Terms: map[string]string{
+		"financialStatement":       "financial statement",
+		"incomeStatementAttribute": "income statement attribute",
+		"maxDepth":                 "maximum depth",
+		"minPostingLevel":          "minimum posting level",
+		"minTopLevelGroups":        "minimum number of top-level groups",
+	},
+}
+
+var BrazilianPortuguese = &MessageCatalog{
+	Messages: map[ErrorCode]string{
+		ErrNameRequired:                      "O nome deve ser informado",
+		ErrNumberRequired:                    "O número deve ser informado",
+		ErrFinancialStatementRequired:        "A demonstração financeira deve ser informada",
+		ErrAmbiguousFinancialStatement:       "A demonstração deve ser balanço patrimonial ou demonstração do resultado",
+		ErrNormalBalanceRequired:             "A natureza do saldo deve ser informada",
+		ErrAmbiguousNormalBalance:            "A natureza do saldo deve ser devedora ou credora",
+		ErrMultipleIncomeStatementAttributes: "Somente um atributo da demonstração do resultado é permitido",
+		ErrDuplicateNumber:                   "Já existe uma conta com este número",
+		ErrParentNotFound:                    "Conta superior não encontrada: {parent}",
+		ErrNumberPrefixMismatch:              "O número deve começar com o número da conta superior",
+		ErrInheritedPropertyMismatch:         "O valor de {property} deve ser igual ao da conta superior",
+		ErrCyclicParent:                      "Uma conta não pode ser movida para baixo de si mesma ou de suas subordinadas",
+		ErrInvalidMask:                       "A máscara deve ser formada por 9s e separadores simples, como 9.9.99",
+		ErrNumberMaskMismatch:                "O número deve seguir a máscara {mask}",
+		ErrNumberLevelMismatch:               "O número deve estar um nível abaixo do número da conta superior",
+		ErrNegativePolicy:                    "O valor de {policy} não deve ser negativo",
+		ErrPostingLevelBeyondMaxDepth:        "O nível mínimo de lançamento não deve exceder a profundidade máxima",
+		ErrMaxDepthExceeded:                  "A conta não deve estar abaixo do nível {maxDepth}",
+		ErrShallowDetailAccount:              "A conta analítica {number} deve estar no nível {minPostingLevel} ou abaixo",
+		ErrTooFewTopLevelGroups:              "O plano deve ter pelo menos {minTopLevelGroups} grupos de primeiro nível",
+	},
+	Terms: map[string]string{
+		"financialStatement":       "demonstração financeira",
+		"incomeStatementAttribute": "atributo da demonstração do resultado",
+		"maxDepth":                 "profundidade máxima",
+		"minPostingLevel":          "nível mínimo de lançamento",
+		"minTopLevelGroups":        "número mínimo de grupos de primeiro nível",
+	},
+}
+
+var Spanish = &MessageCatalog{
+	Messages: map[ErrorCode]string{
+		ErrNameRequired:                      "Se debe informar el nombre",
+		ErrNumberRequired:                    "Se debe informar el número",
+		ErrFinancialStatementRequired:        "Se debe informar el estado financiero",
+		ErrAmbiguousFinancialStatement:       "El estado debe ser balance general o estado de resultados",
+		ErrNormalBalanceRequired:             "Se debe informar la naturaleza del saldo",
+		ErrAmbiguousNormalBalance:            "La naturaleza del saldo debe ser deudora o acreedora",
+		ErrMultipleIncomeStatementAttributes: "Solo se permite un atributo del estado de resultados",
+		ErrDuplicateNumber:                   "Ya existe una cuenta con este número",
+		ErrParentNotFound:                    "Cuenta superior no encontrada: {parent}",
+		ErrNumberPrefixMismatch:              "El número debe comenzar con el número de la cuenta superior",
+		ErrInheritedPropertyMismatch:         "El valor de {property} debe ser igual al de la cuenta superior",
+		ErrCyclicParent:                      "Una cuenta no puede moverse debajo de sí misma o de sus subordinadas",
+		ErrInvalidMask:                       "La máscara debe estar formada por 9s y separadores simples, como 9.9.99",
+		ErrNumberMaskMismatch:                "El número debe seguir la máscara {mask}",
+		ErrNumberLevelMismatch:               "El número debe estar un nivel debajo del número de la cuenta superior",
+		ErrNegativePolicy:                    "El valor de {policy} no debe ser negativo",
+		ErrPostingLevelBeyondMaxDepth:        "El nivel mínimo de registro no debe exceder la profundidad máxima",
+		ErrMaxDepthExceeded:                  "La cuenta no debe estar debajo del nivel {maxDepth}",
+		ErrShallowDetailAccount:              "La cuenta de detalle {number} debe estar en el nivel {minPostingLevel} o debajo",
+		ErrTooFewTopLevelGroups:              "El plan debe tener al menos {minTopLevelGroups} grupos de primer nivel",
+	},
+	Terms: map[string]string{
+		"financialStatement":       "estado financiero",
+		"incomeStatementAttribute": "atributo del estado de resultados",
+		"maxDepth":                 "profundidad máxima",
+		"minPostingLevel":          "nivel mínimo de registro",
+		"minTopLevelGroups":        "número mínimo de grupos de primer nivel",
+	},
+}
+
+var catalogs = struct {
+	sync.RWMutex
+	m map[string]Catalog
+}{m: map[string]Catalog{
+	"en":    English,
+	"pt-BR": BrazilianPortuguese,
+	"es":    Spanish,
+}}
+
+func RegisterCatalog(locale string, c Catalog) {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	catalogs.m[locale] = c
+}
+
+// CatalogFor returns the catalog registered for locale, trying the exact
+// tag first, then any catalog for the same language, then English.
+func CatalogFor(locale string) Catalog {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+	locale = strings.Replace(locale, "_", "-", -1)
+	for l, c := range catalogs.m {
+		if strings.EqualFold(l, locale) {
+			return c
+		}
+	}
+	lang := strings.SplitN(locale, "-", 2)[0]
+	for l, c := range catalogs.m {
+		if strings.EqualFold(strings.SplitN(l, "-", 2)[0], lang) {
+			return c
+		}
+	}
+	return English
+}
@@ -0,0 +1,49 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLocalizedValidationMessages(t *testing.T) {
+	r := NewCoaRepository(store{}, WithLocale("pt-BR"))
+	_, err := r.SaveChartOfAccounts(&ChartOfAccounts{})
+	if err == nil || err.Error() != "O nome deve ser informado" {
+		t.Errorf("Unexpected message %v", err)
+	}
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	a := &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"incomeStatement", "increaseOnDebit"}}
+	msg := a.ValidationMessage(coa.Id, r)
+	if msg != "O valor de demonstração financeira deve ser igual ao da conta superior" {
+		t.Errorf("Unexpected message %q", msg)
+	}
+	_, err = NewCoaRepository(r.store, WithLocale("es_AR")).SaveAccount(coa.Id, a)
+	if err == nil || err.Error() != "El valor de estado financiero debe ser igual al de la cuenta superior" {
+		t.Errorf("Unexpected message %v", err)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError but was %T", err)
+	}
+	if msg := English.Message(verr); msg != "The financial statement must be same as the parent" {
+		t.Errorf("Unexpected message %q", msg)
+	}
+	if CatalogFor("fr") != English {
+		t.Error("Expected English as fallback")
+	}
+	RegisterCatalog("fr", &MessageCatalog{Messages: map[ErrorCode]string{ErrNameRequired: "Le nom doit être renseigné"}})
+	_, err = NewCoaRepository(r.store, WithLocale("fr-CA")).SaveAccount(coa.Id, &Account{Number: "1"})
+	if !errors.As(err, &verr) || verr.Error() != "Le nom doit être renseigné" {
+		t.Errorf("Unexpected message %v", err)
+	}
+	for _, c := range []*MessageCatalog{BrazilianPortuguese, Spanish} {
+		for code := range English.Messages {
+			if _, ok := c.Messages[code]; !ok {
+				t.Errorf("Missing translation for %v", code)
+			}
+		}
+	}
+}
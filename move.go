@@ -0,0 +1,181 @@
+package coa
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MoveAccount places an account under newParentId, or at the top level
+// when it is empty, with newNumber as its number. The numbers of its
+// descendants get newNumber in place of the account's old number as their
+// prefix. The old parent becomes a detail account when left without
+// children and the new parent becomes a summary account.
+func (r *CoaRepository) MoveAccount(coaid string, id string, newParentId string, newNumber string) (*Account, error) {
+	defer r.locks.writeAccounts(coaid, false)()
+	var moved *Account
+	err := r.atomically(func(r *CoaRepository) (err error) {
+		moved, err = r.moveAccount(coaid, id, newParentId, newNumber)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func (r *CoaRepository) moveAccount(coaid string, id string, newParentId string, newNumber string) (*Account, error) {
+	if coaid == "" {
+		return nil, ErrEmptyCoaid
+	}
+	if err := r.checkChartOfAccounts(coaid); err != nil {
+		return nil, err
+	}
+	account, err := r.getAccount(coaid, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("%w: %v", ErrAccountNotFound, id)
+	}
+	if account.Parent == newParentId && account.Number == newNumber {
+		return account, nil
+	}
+	descendants, err := r.descendants(coaid, id)
+	if err != nil {
+		return nil, err
+	}
+	var errs ValidationErrors
+	if newParentId == id {
+		errs.add(ErrCyclicParent, "parent", "parent", newParentId)
+	}
+	for _, d := range descendants {
+		if d.Id == newParentId {
+			errs.add(ErrCyclicParent, "parent", "parent", newParentId)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, r.localize(errs.err())
+	}
+	oldParent, oldNumber := account.Parent, account.Number
+	account.Parent, account.Number = newParentId, newNumber
+	if err := account.validate(coaid, r); err != nil {
+		return nil, err
+	}
+	subtree := append(Accounts{account}, descendants...)
+	inSubtree := make(map[string]bool, len(subtree))
+	for _, a := range subtree {
+		inSubtree[a.Id] = true
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return nil, err
+	}
+	numbers := map[string]string{account.Id: newNumber}
+	if newParentId != "" {
+		parent, err := r.getAccount(coaid, newParentId, false)
+		if err != nil {
+			return nil, err
+		}
+		numbers[newParentId] = parent.Number
+	}
+	changed := Accounts{account}
+	for _, d := range descendants {
+		if strings.HasPrefix(d.Number, oldNumber) && oldNumber != newNumber {
+			d.Number = newNumber + d.Number[len(oldNumber):]
+			changed = append(changed, d)
+		}
+		numbers[d.Id] = d.Number
+	}
+	for _, a := range changed {
+		coa.checkMask(&errs, a.Number, numbers[a.Parent])
+	}
+	level, err := r.level(coaid, newParentId)
+	if err != nil {
+		return nil, err
+	}
+	tree := newAccountTree(subtree)
+	for _, a := range subtree {
+		coa.checkDepth(&errs, "parent", a.Number, level+1+tree.Node(a.Id).Depth, "account", a.Id)
+	}
+	for _, a := range subtree {
+		other, err := r.layout.byNumber(r, coaid, a.Number)
+		if err != nil {
+			return nil, err
+		}
+		if other != nil && !inSubtree[other.Id] {
+			errs.add(ErrDuplicateNumber, "number", "number", a.Number)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, r.localize(errs.err())
+	}
+	now := time.Now()
+	for _, a := range changed {
+		a.AsOf = now
+		a.Version++
+	}
+	if oldParent != newParentId {
+		if oldParent != "" {
+			siblings, err := r.layout.children(r, coaid, oldParent)
+			if err != nil {
+				return nil, err
+			}
+			parent, err := r.getAccount(coaid, oldParent, false)
+			if err != nil {
+				return nil, err
+			}
+			if parent != nil && len(siblings) == 1 && parent.Tags.replace("summary", "detail") {
+				parent.AsOf = now
+				parent.Version++
+				changed = append(changed, parent)
+			}
+		}
+		if newParentId != "" {
+			parent, err := r.getAccount(coaid, newParentId, false)
+			if err != nil {
+				return nil, err
+			}
+			if parent.Tags.replace("detail", "summary") {
+				parent.AsOf = now
+				parent.Version++
+				changed = append(changed, parent)
+			}
+		}
+	}
+	if err := r.saveAccounts(coaid, changed...); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// descendants returns the live descendants of an account, parents before
+// their children.
+func (r *CoaRepository) descendants(coaid string, id string) (Accounts, error) {
+	var result Accounts
+	for queue := []string{id}; len(queue) > 0; queue = queue[1:] {
+		children, err := r.layout.children(r, coaid, queue[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			result = append(result, c)
+			queue = append(queue, c.Id)
+		}
+	}
+	return result, nil
+}
+
+// replace removes tag old and adds tag new, telling whether tags changed.
+func (c *Tags) replace(old string, new string) bool {
+	changed := false
+	if i := c.IndexOf(old); i != -1 {
+		*c = append((*c)[:i], (*c)[i+1:]...)
+		changed = true
+	}
+	if !c.Contains(new) {
+		*c = append(*c, new)
+		changed = true
+	}
+	return changed
+}
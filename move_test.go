@@ -0,0 +1,83 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMoveAccount(t *testing.T) {
+	for _, layout := range []Layout{BlobLayout, PerAccountLayout} {
+		r := NewCoaRepository(store{}, WithLayout(layout))
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		save := func(number string, parent string, tags ...string) *Account {
+			a, err := r.SaveAccount(coa.Id, &Account{Number: number, Name: number, Parent: parent,
+				Tags: append([]string{"balanceSheet", "increaseOnDebit"}, tags...)})
+			check(t, err)
+			return a
+		}
+		a1 := save("1", "")
+		a11 := save("1.1", a1.Id)
+		a111 := save("1.1.1", a11.Id)
+		a1111 := save("1.1.1.1", a111.Id)
+		a2 := save("2", "")
+		save("2.1", a2.Id)
+		moved, err := r.MoveAccount(coa.Id, a11.Id, a2.Id, "2.2")
+		check(t, err)
+		if moved.Number != "2.2" || moved.Parent != a2.Id {
+			t.Errorf("Expected 2.2 under 2 but was %v", moved)
+		}
+		for id, number := range map[string]string{a111.Id: "2.2.1", a1111.Id: "2.2.1.1"} {
+			a, err := r.GetAccount(coa.Id, id)
+			check(t, err)
+			if a.Number != number {
+				t.Errorf("Expected %v but was %v", number, a.Number)
+			}
+		}
+		a, err := r.GetAccount(coa.Id, a1.Id)
+		check(t, err)
+		if !a.Tags.Contains("detail") || a.Tags.Contains("summary") {
+			t.Errorf("Expected 1 to be a detail account but tags were %v", a.Tags)
+		}
+		accounts, err := r.AccountsByNumberPrefix(coa.Id, "2.2")
+		check(t, err)
+		if numbers(accounts) != "2.2 2.2.1 2.2.1.1" {
+			t.Errorf("Expected 2.2 2.2.1 2.2.1.1 but was %v", numbers(accounts))
+		}
+		if _, err := r.MoveAccount(coa.Id, a2.Id, a111.Id, "2.2.1.2"); !errors.Is(err, ErrCyclicParent) {
+			t.Errorf("Expected ErrCyclicParent but was %v", err)
+		}
+		if _, err := r.MoveAccount(coa.Id, a11.Id, a2.Id, "2.1"); !errors.Is(err, ErrDuplicateNumber) {
+			t.Errorf("Expected ErrDuplicateNumber but was %v", err)
+		}
+		if _, err := r.MoveAccount(coa.Id, a11.Id, a1.Id, "2.3"); !errors.Is(err, ErrNumberPrefixMismatch) {
+			t.Errorf("Expected ErrNumberPrefixMismatch but was %v", err)
+		}
+		a3 := save("3", "", "operating")
+		if _, err := r.MoveAccount(coa.Id, a11.Id, a3.Id, "3.1"); !errors.Is(err, ErrInheritedPropertyMismatch) {
+			t.Errorf("Expected ErrInheritedPropertyMismatch but was %v", err)
+		}
+		_, err = r.MoveAccount(coa.Id, a111.Id, a1.Id, "1.1")
+		check(t, err)
+		a, err = r.GetAccount(coa.Id, a1.Id)
+		check(t, err)
+		if !a.Tags.Contains("summary") || a.Tags.Contains("detail") {
+			t.Errorf("Expected 1 to be a summary account but tags were %v", a.Tags)
+		}
+		a, err = r.GetAccount(coa.Id, a11.Id)
+		check(t, err)
+		if !a.Tags.Contains("detail") {
+			t.Errorf("Expected 2.2 to be a detail account but tags were %v", a.Tags)
+		}
+		_, err = r.MoveAccount(coa.Id, a111.Id, "", "4")
+		check(t, err)
+		accounts, err = r.AllAccounts(coa.Id)
+		check(t, err)
+		if numbers(accounts) != "1 2 2.1 2.2 3 4 4.1" {
+			t.Errorf("Expected 1 2 2.1 2.2 3 4 4.1 but was %v", numbers(accounts))
+		}
+		if _, err := r.MoveAccount(coa.Id, "x", "", "5"); !errors.Is(err, ErrAccountNotFound) {
+			t.Errorf("Expected ErrAccountNotFound but was %v", err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package coa
+
+import (
+	"sort"
+	"strings"
+)
+
+// CompareNumbers compares account numbers segment by segment, where a
+// segment is a run of digits or a run of anything else. Digit runs compare
+// numerically, so "1.9" < "1.10" and "2" < "10"; other runs compare
+// lexically. Numbers differing only in leading zeros fall back to a plain
+// comparison, so that the order is total.
+func CompareNumbers(a, b string) int {
+	x, y := a, b
+	for x != "" && y != "" {
+		var sx, sy string
+		sx, x = segment(x)
+		sy, y = segment(y)
+		if c := compareSegments(sx, sy); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case x != "":
+		return 1
+	case y != "":
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func segment(s string) (string, string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func compareSegments(a, b string) int {
+	if !isDigit(a[0]) || !isDigit(b[0]) {
+		return strings.Compare(a, b)
+	}
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareNumbers returns how the chart orders account numbers: with
+// CompareNumbers when NaturalOrdering is set, byte-wise otherwise.
+func (coa *ChartOfAccounts) compareNumbers() func(a, b string) int {
+	if coa != nil && coa.NaturalOrdering {
+		return CompareNumbers
+	}
+	return strings.Compare
+}
+
+// sortAccounts sorts accounts by number in the order of the chart.
+func (coa *ChartOfAccounts) sortAccounts(accounts Accounts) {
+	compare := coa.compareNumbers()
+	sort.SliceStable(accounts, func(i, j int) bool { return compare(accounts[i].Number, accounts[j].Number) < 0 })
+}
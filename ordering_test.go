@@ -0,0 +1,80 @@
+package coa
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareNumbers(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"2", "10", -1},
+		{"1.9", "1.10", -1},
+		{"1.10", "1.9", 1},
+		{"1.2", "1.2.1", -1},
+		{"1.02", "1.2", -1},
+		{"1.2", "1.2", 0},
+		{"1-10", "1-9", 1},
+		{"1.a", "1.b", -1},
+		{"1.9", "1.a", -1},
+		{"", "1", -1},
+	} {
+		if actual := CompareNumbers(c.a, c.b); actual != c.expected {
+			t.Errorf("Expected %v comparing %q and %q but was %v", c.expected, c.a, c.b, actual)
+		}
+	}
+}
+
+func TestNaturalOrdering(t *testing.T) {
+	for _, layout := range []Layout{BlobLayout, PerAccountLayout} {
+		r := NewCoaRepository(scanStore{store{}}, WithLayout(layout))
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		parent, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		ids := map[string]string{}
+		for _, n := range []int{10, 9, 1, 2} {
+			a, err := r.SaveAccount(coa.Id, &Account{Number: fmt.Sprint("1.", n), Name: "a", Parent: parent.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			ids[a.Number] = a.Id
+		}
+		accounts, err := r.AllAccounts(coa.Id)
+		check(t, err)
+		if actual := numbers(accounts); actual != "1 1.1 1.10 1.2 1.9" {
+			t.Errorf("%v: Expected byte-wise order but was %v", layout, actual)
+		}
+		indexes, err := r.Indexes(coa.Id, []string{ids["1.10"], ids["1.2"]}, nil)
+		check(t, err)
+		if fmt.Sprint(indexes) != "[1 4]" {
+			t.Errorf("%v: Expected creation positions [1 4] but was %v", layout, indexes)
+		}
+		coa.NaturalOrdering = true
+		_, err = r.SaveChartOfAccounts(coa)
+		check(t, err)
+		accounts, err = r.AllAccounts(coa.Id)
+		check(t, err)
+		if actual := numbers(accounts); actual != "1 1.1 1.2 1.9 1.10" {
+			t.Errorf("%v: Expected natural order but was %v", layout, actual)
+		}
+		accounts, err = r.AccountsByNumberPrefix(coa.Id, "1.")
+		check(t, err)
+		if actual := numbers(accounts); actual != "1.1 1.2 1.9 1.10" {
+			t.Errorf("%v: Expected natural order by prefix but was %v", layout, actual)
+		}
+		query := []string{ids["1.10"], ids["1.2"], "missing"}
+		indexes, err = r.Indexes(coa.Id, query, nil)
+		check(t, err)
+		if fmt.Sprint(indexes) != "[4 2 -1]" {
+			t.Errorf("%v: Expected positions in natural order [4 2 -1] but was %v", layout, indexes)
+		}
+		accounts, err = r.AllAccounts(coa.Id)
+		check(t, err)
+		for i, id := range query[:2] {
+			if accounts[indexes[i]].Id != id {
+				t.Errorf("%v: Expected position %v to match AllAccounts", layout, indexes[i])
+			}
+		}
+	}
+}
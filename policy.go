@@ -0,0 +1,135 @@
+package coa
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// checkPolicies reports invalid policy settings. Levels start at 1 for
+// top-level accounts and zero disables a policy:
+//
+//	MaxDepth           accounts must not be deeper than this level
+//	MinPostingLevel    only detail accounts at this level or deeper are
+//	                   postable; shallower ones are reported by ValidateChart
+//	MinTopLevelGroups  the chart needs at least this many top-level accounts
+//
+// SaveAccount and MoveAccount enforce MaxDepth. The other policies only hold
+// for a complete chart, so they are reported by ValidateChart alone.
+func (coa *ChartOfAccounts) checkPolicies(errs *ValidationErrors) {
+	for _, p := range []struct {
+		name  string
+		value int
+	}{{"maxDepth", coa.MaxDepth}, {"minPostingLevel", coa.MinPostingLevel}, {"minTopLevelGroups", coa.MinTopLevelGroups}} {
+		if p.value < 0 {
+			errs.add(ErrNegativePolicy, p.name, "policy", p.name)
+		}
+	}
+	if coa.MaxDepth > 0 && coa.MinPostingLevel > coa.MaxDepth {
+		errs.add(ErrPostingLevelBeyondMaxDepth, "minPostingLevel",
+			"minPostingLevel", strconv.Itoa(coa.MinPostingLevel), "maxDepth", strconv.Itoa(coa.MaxDepth))
+	}
+}
+
+// checkDepth reports number, an account at level, if the chart's MaxDepth
+// forbids it.
+func (coa *ChartOfAccounts) checkDepth(errs *ValidationErrors, field string, number string, level int, params ...string) {
+	if coa == nil || coa.MaxDepth <= 0 || level <= coa.MaxDepth {
+		return
+	}
+	errs.add(ErrMaxDepthExceeded, field, append([]string{"number", number,
+		"level", strconv.Itoa(level), "maxDepth", strconv.Itoa(coa.MaxDepth)}, params...)...)
+}
+
+// level returns the level of the account, 1 for top-level ones, or 0 when
+// id is empty.
+func (r *CoaRepository) level(coaid string, id string) (int, error) {
+	level := 0
+	for seen := map[string]bool{}; id != "" && !seen[id]; level++ {
+		seen[id] = true
+		a, err := r.getAccount(coaid, id, true)
+		if err != nil {
+			return 0, err
+		}
+		if a == nil {
+			break
+		}
+		id = a.Parent
+	}
+	return level, nil
+}
+
+// ValidateChart checks the chart and its accounts against the chart's mask
+// and policies, returning every violation found.
+func (r *CoaRepository) ValidateChart(coaid string) error {
+	defer r.locks.readAccounts(coaid)()
+	return r.validateChart(coaid)
+}
+
+func (r *CoaRepository) validateChart(coaid string) error {
+	if coaid == "" {
+		return ErrEmptyCoaid
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return err
+	}
+	if coa == nil {
+		return fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coaid)
+	}
+	var errs ValidationErrors
+	coa.checkPolicies(&errs)
+	accounts, err := r.chartAccounts(coaid, coa, false)
+	if err != nil {
+		return err
+	}
+	tree := newAccountTree(accounts)
+	if len(tree.Roots()) < coa.MinTopLevelGroups {
+		errs.add(ErrTooFewTopLevelGroups, "accounts",
+			"count", strconv.Itoa(len(tree.Roots())), "minTopLevelGroups", strconv.Itoa(coa.MinTopLevelGroups))
+	}
+	for _, a := range accounts {
+		n := tree.Node(a.Id)
+		parentNumber := ""
+		if n.parent != nil {
+			parentNumber = n.parent.Number
+		}
+		coa.checkMask(&errs, a.Number, parentNumber)
+		coa.checkDepth(&errs, "account", a.Number, n.Depth+1, "account", a.Id)
+		if a.Tags.Contains("detail") && n.Depth+1 < coa.MinPostingLevel {
+			errs.add(ErrShallowDetailAccount, "account", "number", a.Number, "account", a.Id,
+				"level", strconv.Itoa(n.Depth+1), "minPostingLevel", strconv.Itoa(coa.MinPostingLevel))
+		}
+	}
+	return r.localize(errs.err())
+}
+
+// Postable tells whether entries may be posted to the account: it must be a
+// live detail account at the chart's MinPostingLevel or deeper.
+func (r *CoaRepository) Postable(coaid string, id string) (bool, error) {
+	defer r.locks.readAccounts(coaid)()
+	if coaid == "" {
+		return false, ErrEmptyCoaid
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return false, err
+	}
+	if coa == nil {
+		return false, fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coaid)
+	}
+	a, err := r.getAccount(coaid, id, false)
+	if err != nil {
+		return false, err
+	}
+	if a == nil {
+		return false, fmt.Errorf("%w: %v", ErrAccountNotFound, id)
+	}
+	if !a.Tags.Contains("detail") {
+		return false, nil
+	}
+	level, err := r.level(coaid, id)
+	if err != nil {
+		return false, err
+	}
+	return level >= coa.MinPostingLevel, nil
+}
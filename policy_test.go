@@ -0,0 +1,83 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChartPolicies(t *testing.T) {
+	r := NewCoaRepository(store{})
+	for _, c := range []struct {
+		coa  ChartOfAccounts
+		code ErrorCode
+	}{
+		{ChartOfAccounts{Name: "coa", MaxDepth: -1}, ErrNegativePolicy},
+		{ChartOfAccounts{Name: "coa", MinTopLevelGroups: -1}, ErrNegativePolicy},
+		{ChartOfAccounts{Name: "coa", MaxDepth: 2, MinPostingLevel: 3}, ErrPostingLevelBeyondMaxDepth},
+	} {
+		if _, err := r.SaveChartOfAccounts(&c.coa); !errors.Is(err, c.code) {
+			t.Errorf("Expected %v for %+v but was %v", c.code, c.coa, err)
+		}
+	}
+	if _, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", MaxDepth: -1}); err == nil || err.Error() != "The maximum depth must not be negative" {
+		t.Errorf("Unexpected message %v", err)
+	}
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa", MaxDepth: 3, MinPostingLevel: 3, MinTopLevelGroups: 2})
+	check(t, err)
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	save := func(number string, parent *Account) *Account {
+		a := &Account{Number: number, Name: number, Tags: tags}
+		if parent != nil {
+			a.Parent = parent.Id
+		}
+		a, err := r.SaveAccount(coa.Id, a)
+		check(t, err)
+		return a
+	}
+	a1 := save("1", nil)
+	a11 := save("1.1", a1)
+	a111 := save("1.1.1", a11)
+	a12 := save("1.2", a1)
+	if _, err := r.SaveAccount(coa.Id, &Account{Number: "1.1.1.1", Name: "a", Parent: a111.Id, Tags: tags}); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Expected ErrMaxDepthExceeded but was %v", err)
+	}
+	if _, err := r.MoveAccount(coa.Id, a11.Id, a12.Id, "1.2.1"); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Expected ErrMaxDepthExceeded moving a subtree but was %v", err)
+	}
+	err = r.ValidateChart(coa.Id)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Code != ErrTooFewTopLevelGroups ||
+		errs[1].Code != ErrShallowDetailAccount || errs[1].Params["account"] != a12.Id {
+		t.Errorf("Expected too few groups and shallow 1.2 but was %v", err)
+	}
+	for _, c := range []struct {
+		a        *Account
+		postable bool
+	}{{a1, false}, {a111, true}, {a12, false}} {
+		postable, err := r.Postable(coa.Id, c.a.Id)
+		check(t, err)
+		if postable != c.postable {
+			t.Errorf("Expected %v postable to be %v", c.a.Number, c.postable)
+		}
+	}
+	save("1.2.1", a12)
+	save("2", nil)
+	if err := r.ValidateChart(coa.Id); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Params["number"] != "2" {
+		t.Errorf("Expected only 2 to be shallow but was %v", err)
+	}
+	coa.MaxDepth = 2
+	coa.MinPostingLevel = 0
+	coa, err = r.SaveChartOfAccounts(coa)
+	check(t, err)
+	if _, err := r.SaveAccount(coa.Id, &Account{Id: a111.Id, Version: a111.Version, Name: "renamed", Tags: tags}); err != nil {
+		t.Errorf("Expected existing accounts to be saved under a tighter policy but was %v", err)
+	}
+	if err := r.ValidateChart(coa.Id); !errors.As(err, &errs) || len(errs) != 2 || errs[0].Code != ErrMaxDepthExceeded {
+		t.Errorf("Expected 1.1.1 and 1.2.1 too deep but was %v", err)
+	}
+	r = NewCoaRepository(r.store, WithLocale("pt-BR"))
+	if err := r.ValidateChart(coa.Id); err == nil ||
+		err.Error() != "A conta não deve estar abaixo do nível 2; A conta não deve estar abaixo do nível 2" {
+		t.Errorf("Unexpected message %v", err)
+	}
+}
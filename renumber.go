@@ -0,0 +1,97 @@
+package coa
+
+import (
+	"strings"
+	"time"
+)
+
+// RenumberAccounts gives every live account of a chart the number returned
+// by rewrite, which gets a copy of the account. Nothing is saved unless all
+// the new numbers are informed, unique and start with their parent's new
+// number. It returns the accounts whose number changed.
+func (r *CoaRepository) RenumberAccounts(coaid string, rewrite func(a *Account) string) (Accounts, error) {
+	defer r.locks.writeAccounts(coaid, false)()
+	var changed Accounts
+	err := r.atomically(func(r *CoaRepository) (err error) {
+		changed, err = r.renumberAccounts(coaid, rewrite)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// RenumberAccountsByMapping renumbers the accounts whose number is a key of
+// numbers, as RenumberAccounts does.
+func (r *CoaRepository) RenumberAccountsByMapping(coaid string, numbers map[string]string) (Accounts, error) {
+	return r.RenumberAccounts(coaid, func(a *Account) string {
+		if number, ok := numbers[a.Number]; ok {
+			return number
+		}
+		return a.Number
+	})
+}
+
+func (r *CoaRepository) renumberAccounts(coaid string, rewrite func(a *Account) string) (Accounts, error) {
+	if coaid == "" {
+		return nil, ErrEmptyCoaid
+	}
+	if err := r.checkChartOfAccounts(coaid); err != nil {
+		return nil, err
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := r.chartAccounts(coaid, coa, false)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make(map[string]string, len(accounts))
+	old := make(map[string]string, len(accounts))
+	for _, a := range accounts {
+		numbers[a.Id] = rewrite(a.clone())
+		old[a.Id] = a.Number
+	}
+	var errs ValidationErrors
+	byNumber := map[string]*Account{}
+	for _, a := range accounts {
+		number := numbers[a.Id]
+		if strings.TrimSpace(number) == "" {
+			errs.add(ErrNumberRequired, "number", "account", a.Id)
+			continue
+		}
+		if other, ok := byNumber[number]; ok {
+			errs.add(ErrDuplicateNumber, "number", "number", number, "account", a.Id, "other", other.Id)
+		}
+		byNumber[number] = a
+		parentNumber, ok := numbers[a.Parent]
+		if ok && !strings.HasPrefix(number, parentNumber) {
+			errs.add(ErrNumberPrefixMismatch, "number", "number", number, "parentNumber", parentNumber, "account", a.Id)
+		}
+		if number != a.Number || parentNumber != old[a.Parent] {
+			coa.checkMask(&errs, number, parentNumber)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, r.localize(errs.err())
+	}
+	now := time.Now()
+	var changed Accounts
+	for _, a := range accounts {
+		if numbers[a.Id] != a.Number {
+			a.Number = numbers[a.Id]
+			a.AsOf = now
+			a.Version++
+			changed = append(changed, a)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := r.saveAccounts(coaid, changed...); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
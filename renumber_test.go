@@ -0,0 +1,67 @@
+package coa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenumberAccounts(t *testing.T) {
+	for _, layout := range []Layout{BlobLayout, PerAccountLayout} {
+		r := NewCoaRepository(store{}, WithLayout(layout))
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		ids := map[string]string{}
+		for _, n := range []string{"1", "1.1", "1.1.01", "1.1.02", "2", "2.1"} {
+			parent := ""
+			if i := strings.LastIndex(n, "."); i != -1 {
+				parent = ids[n[:i]]
+			}
+			a, err := r.SaveAccount(coa.Id, &Account{Number: n, Name: n, Parent: parent, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			ids[n] = a.Id
+		}
+		_, err = r.RenumberAccounts(coa.Id, func(a *Account) string {
+			return strings.Replace(a.Number, ".", "", -1) + "00"
+		})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs) != 4 || !errors.Is(err, ErrNumberPrefixMismatch) {
+			t.Errorf("Expected 4 prefix mismatches but was %v", err)
+		}
+		_, err = r.RenumberAccountsByMapping(coa.Id, map[string]string{"1.1.01": "1.1.02", "2.1": ""})
+		if !errors.Is(err, ErrDuplicateNumber) || !errors.Is(err, ErrNumberRequired) {
+			t.Errorf("Expected ErrDuplicateNumber and ErrNumberRequired but was %v", err)
+		}
+		accounts, err := r.AllAccounts(coa.Id)
+		check(t, err)
+		if numbers(accounts) != "1 1.1 1.1.01 1.1.02 2 2.1" {
+			t.Fatalf("Expected the numbers left untouched but was %v", numbers(accounts))
+		}
+		changed, err := r.RenumberAccounts(coa.Id, func(a *Account) string {
+			return strings.Replace(a.Number, ".", "", -1)
+		})
+		check(t, err)
+		if len(changed) != 4 {
+			t.Errorf("Expected 4 changed accounts but was %v", changed)
+		}
+		accounts, err = r.AllAccounts(coa.Id)
+		check(t, err)
+		if numbers(accounts) != "1 11 1101 1102 2 21" {
+			t.Errorf("Expected 1 11 1101 1102 2 21 but was %v", numbers(accounts))
+		}
+		_, err = r.RenumberAccountsByMapping(coa.Id, map[string]string{"1101": "1102", "1102": "1101"})
+		check(t, err)
+		a, err := r.GetAccount(coa.Id, ids["1.1.01"])
+		check(t, err)
+		if a.Number != "1102" || a.Version != 3 {
+			t.Errorf("Expected 1102 in version 3 but was %v in %v", a.Number, a.Version)
+		}
+		accounts, err = r.AccountsByNumberPrefix(coa.Id, "110")
+		check(t, err)
+		if len(accounts) != 2 || accounts[0].Id != ids["1.1.02"] || accounts[1].Id != ids["1.1.01"] {
+			t.Errorf("Expected the swapped accounts but was %v", accounts)
+		}
+		_, err = r.SaveAccount(coa.Id, &Account{Number: "1103", Name: "new", Parent: ids["1.1"], Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+	}
+}
@@ -0,0 +1,106 @@
+package coa
+
+import (
+	"sort"
+	"strings"
+)
+
+// ScannableKeyValueStore is implemented by stores that keep their keys
+// ordered and can delete them. CoaRepository detects it and then keeps one
+// key per chart of accounts and per account revision, and answers number
+// prefix queries with a range scan, instead of reading whole collections
+// stored under a single key.
+type ScannableKeyValueStore interface {
+	KeyValueStore
+	// Scan calls fn for every key starting with prefix, in ascending key
+	// order, stopping at the first error.
+	Scan(prefix []byte, fn func(key []byte, value []byte) error) error
+	Delete(key []byte) error
+}
+
+func (r *CoaRepository) scannable() bool {
+	_, ok := r.store.(ScannableKeyValueStore)
+	return ok
+}
+
+func (r *CoaRepository) scan(prefix string, fn func(key string, data []byte) error) error {
+	var rewrites []string
+	err := r.store.(ScannableKeyValueStore).Scan([]byte(prefix), func(key []byte, value []byte) error {
+		if r.rewriteOnRead && outdated(value) {
+			rewrites = append(rewrites, string(key))
+		}
+		return fn(string(key), value)
+	})
+	if err != nil {
+		return err
+	}
+	// not while scanning, as stores may not allow writes then
+	for _, key := range rewrites {
+		if err := r.rewrite(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete removes key, or stores an empty value, which get reads as missing,
+// when the store cannot delete.
+func (r *CoaRepository) delete(key string) error {
+	if s, ok := r.store.(ScannableKeyValueStore); ok {
+		return s.Delete([]byte(key))
+	}
+	return r.store.Put([]byte(key), []byte{})
+}
+
+// scanChartsOfAccounts adds the charts stored one per key to the ones
+// still found in the single "charts-of-accounts" key.
+func (r *CoaRepository) scanChartsOfAccounts(coas ChartsOfAccounts) (ChartsOfAccounts, error) {
+	err := r.scan("charts-of-accounts/", func(key string, data []byte) error {
+		coa := &ChartOfAccounts{}
+		if err := r.decode(key, data, coa); err != nil {
+			return err
+		}
+		coas = append(coas, coa)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(coas, func(i, j int) bool { return strings.Compare(coas[i].Name, coas[j].Name) < 0 })
+	return coas, nil
+}
+
+// putChartOfAccounts writes the changed chart under its own key. Charts
+// still kept in the single "charts-of-accounts" key are moved out of it.
+func (r *CoaRepository) putChartOfAccounts(coas ChartsOfAccounts, changed *ChartOfAccounts) error {
+	data, err := r.store.Get([]byte("charts-of-accounts"))
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return r.put("charts-of-accounts/"+changed.Id, changed)
+	}
+	for _, coa := range coas {
+		if err := r.put("charts-of-accounts/"+coa.Id, coa); err != nil {
+			return err
+		}
+	}
+	return r.delete("charts-of-accounts")
+}
+
+func (r *CoaRepository) AccountsByNumberPrefix(coaid string, prefix string) (Accounts, error) {
+	defer r.locks.readAccounts(coaid)()
+	if coaid == "" {
+		return nil, ErrEmptyCoaid
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := r.layout.byNumberPrefix(r, coaid, prefix)
+	if err != nil {
+		return nil, err
+	}
+	coa.sortAccounts(accounts)
+	return accounts, nil
+}
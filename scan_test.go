@@ -0,0 +1,181 @@
+package coa
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// scanStore is a store that can also scan and delete keys.
+type scanStore struct {
+	store
+}
+
+func (s scanStore) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	var keys []string
+	for k := range s.store {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, _ := s.store.Get([]byte(k))
+		if err := fn([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s scanStore) Delete(key []byte) error {
+	delete(s.store, string(key))
+	return nil
+}
+
+func TestChartsOfAccountsInScannableStore(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s)
+	legacy, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "b"})
+	check(t, err)
+	r = NewCoaRepository(scanStore{s})
+	coas, err := r.AllChartsOfAccounts()
+	check(t, err)
+	if len(coas) != 1 || coas[0].Id != legacy.Id {
+		t.Fatalf("Expected the legacy coa but was %v", coas)
+	}
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "a"})
+	check(t, err)
+	if _, ok := s["charts-of-accounts"]; ok {
+		t.Error("Expected the single key to be removed")
+	}
+	if _, ok := s["charts-of-accounts/"+legacy.Id]; !ok {
+		t.Error("Expected the legacy coa under its own key")
+	}
+	check(t, r.RemoveChartOfAccounts(legacy.Id))
+	coas, err = r.AllChartsOfAccountsIncludingRemoved()
+	check(t, err)
+	if len(coas) != 2 || coas[0].Id != coa.Id || coas[1].Removed.IsZero() {
+		t.Errorf("Expected a and removed b but was %v", coas)
+	}
+}
+
+func TestSaveUnknownChartOfAccounts(t *testing.T) {
+	for name, s := range map[string]KeyValueStore{"plain": store{}, "scan": scanStore{store{}}} {
+		r := NewCoaRepository(s)
+		_, err := r.SaveChartOfAccounts(&ChartOfAccounts{Id: "unknown", Name: "coa", Version: 1})
+		if !errors.Is(err, ErrChartOfAccountsNotFound) {
+			t.Errorf("%v: Expected ErrChartOfAccountsNotFound but was %v", name, err)
+		}
+		coas, err := r.AllChartsOfAccounts()
+		check(t, err)
+		if len(coas) != 0 {
+			t.Errorf("%v: Expected no charts but was %v", name, coas)
+		}
+	}
+}
+
+func TestAccountsByNumberPrefix(t *testing.T) {
+	for name, r := range map[string]*CoaRepository{
+		"blob":             NewCoaRepository(store{}),
+		"blob scan":        NewCoaRepository(scanStore{store{}}),
+		"per account":      NewCoaRepository(store{}, WithLayout(PerAccountLayout)),
+		"per account scan": NewCoaRepository(scanStore{store{}}, WithLayout(PerAccountLayout)),
+	} {
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		var a12 *Account
+		for _, n := range []string{"12", "11", "121"} {
+			a, err := r.SaveAccount(coa.Id, &Account{Number: n, Name: n, Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+			check(t, err)
+			if n == "12" {
+				a12 = a
+			}
+		}
+		_, err = r.SaveAccount(coa.Id, &Account{Number: "2", Name: "a2", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		accounts, err := r.AccountsByNumberPrefix(coa.Id, "1")
+		check(t, err)
+		if numbers(accounts) != "1 11 12 121" {
+			t.Errorf("%v: Expected 1 11 12 121 but was %v", name, numbers(accounts))
+		}
+		check(t, r.DeleteAccount(coa.Id, a12.Id))
+		accounts, err = r.AccountsByNumberPrefix(coa.Id, "12")
+		check(t, err)
+		if numbers(accounts) != "121" {
+			t.Errorf("%v: Expected 121 but was %v", name, numbers(accounts))
+		}
+	}
+}
+
+func TestAccountHistory(t *testing.T) {
+	for name, s := range map[string]KeyValueStore{"plain": store{}, "scan": scanStore{store{}}} {
+		r := NewCoaRepository(s, WithLayout(PerAccountLayout), WithHistory())
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "first", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		a.Name = "second"
+		_, err = r.SaveAccount(coa.Id, a)
+		check(t, err)
+		check(t, r.DeleteAccount(coa.Id, a.Id))
+		history, err := r.AccountHistory(coa.Id, a.Id)
+		check(t, err)
+		if len(history) != 3 {
+			t.Fatalf("%v: Expected 3 revisions but was %v", name, len(history))
+		}
+		if history[0].Name != "first" || history[1].Name != "second" || history[2].Removed.IsZero() {
+			t.Errorf("%v: Unexpected history %v", name, history)
+		}
+		for i, a := range history {
+			if a.Version != i+1 {
+				t.Errorf("%v: Expected version %v but was %v", name, i+1, a.Version)
+			}
+		}
+	}
+}
+
+func TestScannableStoreDeletesEmptyIndexes(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(scanStore{s}, WithLayout(PerAccountLayout))
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	check(t, r.DeleteAccount(coa.Id, a.Id))
+	for k := range s {
+		if strings.Contains(k, "/number/") || strings.Contains(k, "/children/") {
+			t.Errorf("Expected %v to be deleted", k)
+		}
+	}
+}
+
+func numbers(accounts Accounts) string {
+	ss := make([]string, len(accounts))
+	for i, a := range accounts {
+		ss[i] = a.Number
+	}
+	return strings.Join(ss, " ")
+}
+
+func TestAccountHistoryIsOptIn(t *testing.T) {
+	s := store{}
+	r := NewCoaRepository(s)
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "first", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+	check(t, err)
+	for k := range s {
+		if strings.HasPrefix(k, "history/") {
+			t.Errorf("Expected no history but found %v", k)
+		}
+	}
+	history, err := r.AccountHistory(coa.Id, a.Id)
+	check(t, err)
+	if len(history) != 0 {
+		t.Errorf("Expected no revisions but was %v", len(history))
+	}
+}
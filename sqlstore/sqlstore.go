@@ -0,0 +1,199 @@
+// Package sqlstore is a coa.KeyValueStore kept in a key/value table of a
+// database/sql database.
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-accounting/coa"
+)
+
+// Dialect holds the SQL that differs between databases. Keys must compare
+// bytewise for Scan to return them in order.
+type Dialect struct {
+	KeyType   string
+	ValueType string
+	// Placeholder returns the nth (from 1) bind parameter.
+	Placeholder func(n int) string
+}
+
+var PostgreSQL = Dialect{
+	KeyType:     `TEXT COLLATE "C"`,
+	ValueType:   "BYTEA",
+	Placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+}
+
+var SQLite = Dialect{
+	KeyType:     "TEXT",
+	ValueType:   "BLOB",
+	Placeholder: func(int) string { return "?" },
+}
+
+type Option func(*Store)
+
+func WithDialect(d Dialect) Option {
+	return func(s *Store) { s.dialect = d }
+}
+
+// WithTable sets the table name, "coa" by default. It is spliced into the
+// statements as is.
+func WithTable(name string) Option {
+	return func(s *Store) { s.table = name }
+}
+
+// Store implements coa.TransactionalKeyValueStore and
+// coa.ScannableKeyValueStore.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+// New returns a store using db, by default in the PostgreSQL dialect.
+func New(db *sql.DB, options ...Option) *Store {
+	s := &Store{db: db, dialect: PostgreSQL, table: "coa"}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// CreateSchema creates the table unless it already exists.
+func (s *Store) CreateSchema() error {
+	_, err := s.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (key %v PRIMARY KEY, value %v NOT NULL)",
+		s.table, s.dialect.KeyType, s.dialect.ValueType))
+	return err
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	return s.queries(s.db).Get(key)
+}
+
+func (s *Store) Put(key []byte, value []byte) error {
+	return s.queries(s.db).Put(key, value)
+}
+
+func (s *Store) Delete(key []byte) error {
+	return s.queries(s.db).Delete(key)
+}
+
+func (s *Store) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	return s.queries(s.db).Scan(prefix, fn)
+}
+
+// Update runs fn in a single database transaction, committed only if fn
+// returns nil.
+func (s *Store) Update(fn func(tx coa.KeyValueStore) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(s.queries(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type queries struct {
+	*Store
+	q querier
+}
+
+func (s *Store) queries(q querier) queries {
+	return queries{s, q}
+}
+
+// sql fills in the table name and turns each ? into the dialect's
+// placeholder.
+func (s queries) sql(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range strings.Replace(query, "{table}", s.table, -1) {
+		if r == '?' {
+			n++
+			b.WriteString(s.dialect.Placeholder(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (s queries) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.q.QueryRow(s.sql("SELECT value FROM {table} WHERE key = ?"), string(key)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s queries) Put(key []byte, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.q.Exec(s.sql("INSERT INTO {table} (key, value) VALUES (?, ?) "+
+		"ON CONFLICT (key) DO UPDATE SET value = excluded.value"), string(key), value)
+	return err
+}
+
+func (s queries) Delete(key []byte) error {
+	_, err := s.q.Exec(s.sql("DELETE FROM {table} WHERE key = ?"), string(key))
+	return err
+}
+
+// Scan reads all matching rows before calling fn, so fn is free to use the
+// store, or the transaction, while scanning.
+func (s queries) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	query, args := "SELECT key, value FROM {table} WHERE key >= ?", []interface{}{string(prefix)}
+	if end := prefixEnd(prefix); end != nil {
+		query, args = query+" AND key < ?", append(args, string(end))
+	}
+	rows, err := s.q.Query(s.sql(query+" ORDER BY key"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var keys, values [][]byte
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		keys, values = append(keys, []byte(key)), append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	for i := range keys {
+		if err := fn(keys[i], values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or nil when there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
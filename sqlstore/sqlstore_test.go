@@ -0,0 +1,58 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-accounting/coa"
+	"github.com/go-accounting/coa/storetest"
+	_ "modernc.org/sqlite"
+)
+
+func open(t *testing.T) *Store {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "coa.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := New(db, WithDialect(SQLite))
+	if err := s.CreateSchema(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) coa.KeyValueStore { return open(t) })
+}
+
+func TestCreateSchemaIsIdempotent(t *testing.T) {
+	s := open(t)
+	if err := s.Put([]byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateSchema(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Get([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v) != "v" {
+		t.Errorf("Expected v but was %q", v)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	s := New(nil, WithTable("charts")).queries(nil)
+	actual := s.sql("INSERT INTO {table} (key, value) VALUES (?, ?)")
+	if expected := "INSERT INTO charts (key, value) VALUES ($1, $2)"; actual != expected {
+		t.Errorf("Expected %v but was %v", expected, actual)
+	}
+	for prefix, expected := range map[string]string{"a/": "a0", "a\xff": "b", "\xff\xff": ""} {
+		if actual := string(prefixEnd([]byte(prefix))); actual != expected {
+			t.Errorf("Expected %q for %q but was %q", expected, prefix, actual)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package storetest checks that a coa.KeyValueStore honors the contract
+// CoaRepository relies on:
+//
+//   - Get of a missing key returns a nil or empty value and no error; the
+//     repository reads an empty value as "not found".
+//   - Put stores a copy of value, and Get returns a slice the caller owns:
+//     modifying either afterwards must not change what is stored.
+//   - Put replaces the whole value, whatever the sizes of the old and new
+//     values, and values of several megabytes are accepted.
+//   - Get and Put may be called from several goroutines at once.
+//
+// Stores also implementing coa.ScannableKeyValueStore or
+// coa.TransactionalKeyValueStore are checked against those contracts too.
+package storetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-accounting/coa"
+)
+
+// RunConformance runs the conformance checks as subtests of t. factory must
+// return a new, empty store each time it is called.
+func RunConformance(t *testing.T, factory func(t *testing.T) coa.KeyValueStore) {
+	t.Run("MissingKeys", func(t *testing.T) { testMissingKeys(t, factory(t)) })
+	t.Run("CopySemantics", func(t *testing.T) { testCopySemantics(t, factory(t)) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, factory(t)) })
+	t.Run("LargeValues", func(t *testing.T) { testLargeValues(t, factory(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, factory(t)) })
+	t.Run("Scan", func(t *testing.T) { testScan(t, factory(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, factory(t)) })
+	t.Run("Repository", func(t *testing.T) { testRepository(t, factory(t)) })
+}
+
+func testMissingKeys(t *testing.T, s coa.KeyValueStore) {
+	for _, key := range []string{"missing", "charts-of-accounts", "accounts/c1", "accounts/c1/ids"} {
+		expect(t, s, key, "")
+	}
+	check(t, s.Put([]byte("accounts/c1/ids"), []byte("v")))
+	expect(t, s, "accounts/c1", "")
+	expect(t, s, "accounts/c1/id", "")
+	expect(t, s, "accounts/c1/ids/x", "")
+	check(t, s.Put([]byte("accounts/c1"), []byte{}))
+	expect(t, s, "accounts/c1", "")
+	check(t, s.Put([]byte("accounts/c2"), nil))
+	expect(t, s, "accounts/c2", "")
+}
+
+func testCopySemantics(t *testing.T, s coa.KeyValueStore) {
+	key := []byte("accounts/c1")
+	value := []byte("value")
+	check(t, s.Put(key, value))
+	copy(value, "xxxxx")
+	copy(key, "xxxxxxxx")
+	expect(t, s, "accounts/c1", "value")
+	v, err := s.Get([]byte("accounts/c1"))
+	check(t, err)
+	copy(v, "yyyyy")
+	expect(t, s, "accounts/c1", "value")
+}
+
+func testOverwrite(t *testing.T, s coa.KeyValueStore) {
+	for _, v := range []string{"short", "a much longer value", "mid value", "x"} {
+		check(t, s.Put([]byte("charts-of-accounts"), []byte(v)))
+		expect(t, s, "charts-of-accounts", v)
+	}
+}
+
+func testLargeValues(t *testing.T, s coa.KeyValueStore) {
+	value := make([]byte, 8<<20)
+	for i := range value {
+		value[i] = byte(i * 7)
+	}
+	check(t, s.Put([]byte("accounts/c1"), value))
+	v, err := s.Get([]byte("accounts/c1"))
+	check(t, err)
+	if !bytes.Equal(v, value) {
+		t.Errorf("Expected the %v bytes back but got %v different ones", len(value), len(v))
+	}
+}
+
+func testConcurrency(t *testing.T, s coa.KeyValueStore) {
+	const goroutines, rounds = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*rounds*2)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			own := []byte(fmt.Sprint("accounts/c", g))
+			for i := 0; i < rounds; i++ {
+				value := fmt.Sprint(g, " ", i)
+				if err := s.Put(own, []byte(value)); err != nil {
+					errs <- err
+					continue
+				}
+				if v, err := s.Get(own); err != nil || string(v) != value {
+					errs <- fmt.Errorf("expected %q for %s but was %q, %v", value, own, v, err)
+				}
+				if err := s.Put([]byte("charts-of-accounts"), []byte(value)); err != nil {
+					errs <- err
+				}
+				if _, err := s.Get([]byte("charts-of-accounts")); err != nil {
+					errs <- err
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	for g := 0; g < goroutines; g++ {
+		expect(t, s, fmt.Sprint("accounts/c", g), fmt.Sprint(g, " ", rounds-1))
+	}
+}
+
+func testScan(t *testing.T, s coa.KeyValueStore) {
+	ss, ok := s.(coa.ScannableKeyValueStore)
+	if !ok {
+		t.Skip("store is not scannable")
+	}
+	keys := []string{
+		"accounts/c1/number/2", "accounts/c1/number/11", "accounts/c1/number/1",
+		"accounts/c1", "accounts/c2/number/1", "charts-of-accounts/c1",
+	}
+	for _, key := range keys {
+		check(t, ss.Put([]byte(key), []byte(key)))
+	}
+	check(t, ss.Delete([]byte("accounts/c1/number/2")))
+	check(t, ss.Delete([]byte("missing")))
+	expect(t, ss, "accounts/c1/number/2", "")
+	var scanned []string
+	check(t, ss.Scan([]byte("accounts/c1/number/"), func(key []byte, value []byte) error {
+		if !bytes.Equal(key, value) {
+			t.Errorf("Expected value %q but was %q", key, value)
+		}
+		scanned = append(scanned, string(key))
+		return nil
+	}))
+	if len(scanned) != 2 || scanned[0] != "accounts/c1/number/1" || scanned[1] != "accounts/c1/number/11" {
+		t.Errorf("Expected number 1 and 11 in order but was %v", scanned)
+	}
+	stop := errors.New("stop")
+	n := 0
+	err := ss.Scan([]byte("accounts/"), func(key []byte, value []byte) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("Expected the scan to stop at the first error but was %v after %v keys", err, n)
+	}
+}
+
+func testUpdate(t *testing.T, s coa.KeyValueStore) {
+	ts, ok := s.(coa.TransactionalKeyValueStore)
+	if !ok {
+		t.Skip("store is not transactional")
+	}
+	check(t, ts.Put([]byte("accounts/c1"), []byte("old")))
+	fail := errors.New("fail")
+	err := ts.Update(func(tx coa.KeyValueStore) error {
+		check(t, tx.Put([]byte("accounts/c1"), []byte("new")))
+		check(t, tx.Put([]byte("accounts/c2"), []byte("new")))
+		expect(t, tx, "accounts/c1", "new")
+		return fail
+	})
+	if err != fail {
+		t.Errorf("Expected the error returned by fn but was %v", err)
+	}
+	expect(t, ts, "accounts/c1", "old")
+	expect(t, ts, "accounts/c2", "")
+	check(t, ts.Update(func(tx coa.KeyValueStore) error {
+		return tx.Put([]byte("accounts/c1"), []byte("new"))
+	}))
+	expect(t, ts, "accounts/c1", "new")
+}
+
+func testRepository(t *testing.T, s coa.KeyValueStore) {
+	for _, layout := range []coa.Layout{coa.BlobLayout, coa.PerAccountLayout} {
+		r := coa.NewCoaRepository(s, coa.WithLayout(layout))
+		c, err := r.SaveChartOfAccounts(&coa.ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		a1, err := r.SaveAccount(c.Id, &coa.Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		_, err = r.SaveAccount(c.Id, &coa.Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		accounts, err := r.AllAccounts(c.Id)
+		check(t, err)
+		if len(accounts) != 2 || !accounts[0].Tags.Contains("summary") {
+			t.Errorf("Expected a summary 1 and 11 but was %v", accounts)
+		}
+		accounts, err = r.AccountsByNumberPrefix(c.Id, "11")
+		check(t, err)
+		if len(accounts) != 1 || accounts[0].Number != "11" {
+			t.Errorf("Expected 11 but was %v", accounts)
+		}
+	}
+}
+
+func expect(t *testing.T, s coa.KeyValueStore, key string, value string) {
+	t.Helper()
+	v, err := s.Get([]byte(key))
+	check(t, err)
+	if string(v) != value {
+		t.Errorf("Expected %q for %v but was %q", value, key, v)
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,31 @@
+package storetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-accounting/coa"
+)
+
+// mapStore is the smallest store meeting the contract.
+type mapStore struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+func (s *mapStore) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]byte(nil), s.m[string(key)]...), nil
+}
+
+func (s *mapStore) Put(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func TestRunConformance(t *testing.T) {
+	RunConformance(t, func(t *testing.T) coa.KeyValueStore { return &mapStore{m: map[string][]byte{}} })
+}
@@ -0,0 +1,152 @@
+package coa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithAutoNumber makes SaveAccount assign SuggestNumber to new accounts
+// saved without a number.
+func WithAutoNumber() Option {
+	return func(r *CoaRepository) { r.autoNumber = true }
+}
+
+// SuggestNumber returns a free number for a new child of parentId, or for a
+// new top-level account when parentId is empty. Charts with a mask get
+// NextNumber; otherwise the number follows the style of the siblings, or of
+// the children of accounts at the parent's depth when there are none.
+func (r *CoaRepository) SuggestNumber(coaid string, parentId string) (string, error) {
+	defer r.locks.readAccounts(coaid)()
+	return r.suggestNumber(coaid, parentId)
+}
+
+func (r *CoaRepository) suggestNumber(coaid string, parentId string) (string, error) {
+	if coaid == "" {
+		return "", ErrEmptyCoaid
+	}
+	coa, err := r.getChartOfAccounts(coaid, true)
+	if err != nil {
+		return "", err
+	}
+	if coa == nil {
+		return "", fmt.Errorf("%w: %v", ErrChartOfAccountsNotFound, coaid)
+	}
+	accounts, err := r.chartAccounts(coaid, coa, false)
+	if err != nil {
+		return "", err
+	}
+	tree := newAccountTree(accounts)
+	parentNumber, depth, siblings := "", 0, tree.Roots()
+	if parentId != "" {
+		parent := tree.Node(parentId)
+		if parent == nil {
+			return "", fmt.Errorf("%w: %v", ErrAccountNotFound, parentId)
+		}
+		parentNumber, depth, siblings = parent.Number, parent.Depth+1, parent.Children
+	}
+	taken := make(map[string]bool, len(accounts))
+	numbers := make([]string, len(accounts))
+	for i, a := range accounts {
+		taken[a.Number] = true
+		numbers[i] = a.Number
+	}
+	if coa.Mask != "" {
+		return coa.NextNumber(parentNumber, numbers)
+	}
+	style, highest, ok := siblingStyle(parentNumber, siblings)
+	if !ok {
+		style = cousinStyle(tree, accounts, depth)
+	}
+	for n := highest + 1; ; n++ {
+		number := parentNumber + style.format(n)
+		if !taken[number] {
+			return number, nil
+		}
+	}
+}
+
+// numberStyle is how a child's number extends its parent's: a separator
+// followed by a sequence number, zero padded to width.
+type numberStyle struct {
+	separator string
+	width     int
+}
+
+func (s numberStyle) format(n int) string {
+	return fmt.Sprintf("%v%0*d", s.separator, s.width, n)
+}
+
+// childNumber splits number into the style and sequence number of a child
+// of parentNumber, if it ends in digits preceded by a non-digit separator.
+func childNumber(parentNumber string, number string) (numberStyle, int, bool) {
+	if !strings.HasPrefix(number, parentNumber) {
+		return numberStyle{}, 0, false
+	}
+	rest := number[len(parentNumber):]
+	i := len(rest)
+	for i > 0 && isDigit(rest[i-1]) {
+		i--
+	}
+	separator, seq := rest[:i], rest[i:]
+	n, err := strconv.Atoi(seq)
+	if err != nil || strings.ContainsAny(separator, "0123456789") {
+		return numberStyle{}, 0, false
+	}
+	style := numberStyle{separator: separator}
+	if len(seq) > 1 && seq[0] == '0' {
+		style.width = len(seq)
+	}
+	return style, n, true
+}
+
+// siblingStyle returns the style of the sibling with the highest sequence
+// number, padded as widely as any sibling, and that number.
+func siblingStyle(parentNumber string, siblings []*AccountNode) (numberStyle, int, bool) {
+	var style numberStyle
+	highest, found := 0, false
+	for _, s := range siblings {
+		st, n, ok := childNumber(parentNumber, s.Number)
+		if !ok {
+			continue
+		}
+		if !found || n > highest {
+			highest, style.separator = n, st.separator
+		}
+		if st.width > style.width {
+			style.width = st.width
+		}
+		found = true
+	}
+	return style, highest, found
+}
+
+// cousinStyle returns the style of the accounts at depth, or of any child
+// account when there are none, with "." as the separator by default below
+// the top level.
+func cousinStyle(tree *AccountTree, accounts Accounts, depth int) numberStyle {
+	if depth == 0 {
+		return numberStyle{}
+	}
+	var fallback *numberStyle
+	for _, a := range accounts {
+		n := tree.Node(a.Id)
+		if n.parent == nil {
+			continue
+		}
+		style, _, ok := childNumber(n.parent.Number, n.Number)
+		if !ok {
+			continue
+		}
+		if n.Depth == depth {
+			return style
+		}
+		if fallback == nil {
+			fallback = &style
+		}
+	}
+	if fallback != nil {
+		return *fallback
+	}
+	return numberStyle{separator: "."}
+}
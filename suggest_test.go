@@ -0,0 +1,77 @@
+package coa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSuggestNumber(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	if number, err := r.SuggestNumber(coa.Id, ""); err != nil || number != "1" {
+		t.Errorf("Expected 1 for an empty chart but was %v %v", number, err)
+	}
+	ids := map[string]string{}
+	for _, n := range []string{"1", "2", "1.01", "1.02", "1.09", "2.1", "2.1-3", "3"} {
+		parent := ""
+		if i := strings.LastIndexAny(n, ".-"); i != -1 {
+			parent = ids[n[:i]]
+		}
+		a, err := r.SaveAccount(coa.Id, &Account{Number: n, Name: n, Parent: parent, Tags: tags})
+		check(t, err)
+		ids[n] = a.Id
+	}
+	check(t, r.DeleteAccount(coa.Id, ids["3"]))
+	for parent, expected := range map[string]string{
+		"":      "3",
+		"1":     "1.10",
+		"2":     "2.2",
+		"2.1":   "2.1-4",
+		"1.01":  "1.01-1",
+		"2.1-3": "2.1-3.01",
+	} {
+		number, err := r.SuggestNumber(coa.Id, ids[parent])
+		check(t, err)
+		if number != expected {
+			t.Errorf("Expected %v under %q but was %v", expected, parent, number)
+		}
+	}
+	if _, err := r.SuggestNumber(coa.Id, "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound but was %v", err)
+	}
+	masked, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "masked", Mask: "9.99"})
+	check(t, err)
+	a, err := r.SaveAccount(masked.Id, &Account{Number: "1", Name: "a", Tags: tags})
+	check(t, err)
+	if number, err := r.SuggestNumber(masked.Id, a.Id); err != nil || number != "1.01" {
+		t.Errorf("Expected 1.01 from the mask but was %v %v", number, err)
+	}
+}
+
+func TestSaveAccountAutoNumber(t *testing.T) {
+	tags := []string{"balanceSheet", "increaseOnDebit"}
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	if _, err := r.SaveAccount(coa.Id, &Account{Name: "a", Tags: tags}); !errors.Is(err, ErrNumberRequired) {
+		t.Errorf("Expected ErrNumberRequired without WithAutoNumber but was %v", err)
+	}
+	r = NewCoaRepository(store{}, WithAutoNumber())
+	coa, err = r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	a1, err := r.SaveAccount(coa.Id, &Account{Name: "a1", Tags: tags})
+	check(t, err)
+	a11, err := r.SaveAccount(coa.Id, &Account{Name: "a11", Parent: a1.Id, Tags: tags})
+	check(t, err)
+	a12, err := r.SaveAccount(coa.Id, &Account{Name: "a12", Parent: a1.Id, Tags: tags})
+	check(t, err)
+	if a1.Number != "1" || a11.Number != "1.1" || a12.Number != "1.2" {
+		t.Errorf("Expected 1 1.1 1.2 but was %v %v %v", a1.Number, a11.Number, a12.Number)
+	}
+	if _, err := r.SaveAccount(coa.Id, &Account{Name: "a", Parent: "missing", Tags: tags}); !errors.Is(err, ErrParentNotFound) {
+		t.Errorf("Expected ErrParentNotFound but was %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package coa
+
+// AccountTree is a chart's live accounts arranged by parent, built from a
+// single read of the chart. Accounts whose parent is missing are roots.
+type AccountTree struct {
+	roots []*AccountNode
+	nodes map[string]*AccountNode
+}
+
+type AccountNode struct {
+	*Account
+	Children []*AccountNode
+	// Depth is 0 for roots.
+	Depth int
+	// Path holds the ids from the root down to the account itself.
+	Path   []string
+	parent *AccountNode
+}
+
+func (r *CoaRepository) AccountTree(coaid string) (*AccountTree, error) {
+	defer r.locks.readAccounts(coaid)()
+	accounts, err := r.allAccounts(coaid, false)
+	if err != nil {
+		return nil, err
+	}
+	return newAccountTree(accounts), nil
+}
+
+// newAccountTree arranges accounts, keeping their order among siblings.
+func newAccountTree(accounts Accounts) *AccountTree {
+	t := &AccountTree{nodes: make(map[string]*AccountNode, len(accounts))}
+	for _, a := range accounts {
+		t.nodes[a.Id] = &AccountNode{Account: a}
+	}
+	for _, a := range accounts {
+		n := t.nodes[a.Id]
+		if parent, ok := t.nodes[a.Parent]; ok && a.Parent != a.Id {
+			n.parent = parent
+			parent.Children = append(parent.Children, n)
+		} else {
+			t.roots = append(t.roots, n)
+		}
+	}
+	var walk func(n *AccountNode, depth int, path []string)
+	walk = func(n *AccountNode, depth int, path []string) {
+		n.Depth = depth
+		n.Path = append(path[:len(path):len(path)], n.Id)
+		for _, c := range n.Children {
+			walk(c, depth+1, n.Path)
+		}
+	}
+	for _, n := range t.roots {
+		walk(n, 0, nil)
+	}
+	return t
+}
+
+func (t *AccountTree) Roots() []*AccountNode {
+	return t.roots
+}
+
+// Node returns the account's node, nil if it is not in the tree.
+func (t *AccountTree) Node(id string) *AccountNode {
+	return t.nodes[id]
+}
+
+// Ancestors returns the account's parent, its parent's parent and so on up
+// to the root.
+func (t *AccountTree) Ancestors(id string) []*AccountNode {
+	n := t.nodes[id]
+	if n == nil {
+		return nil
+	}
+	var result []*AccountNode
+	// bounded, in case corrupt data has a cycle
+	for p := n.parent; p != nil && len(result) < len(t.nodes); p = p.parent {
+		result = append(result, p)
+	}
+	return result
+}
+
+// Descendants returns the account's descendants depth first, each one
+// followed by its own descendants.
+func (t *AccountTree) Descendants(id string) []*AccountNode {
+	n := t.nodes[id]
+	if n == nil {
+		return nil
+	}
+	var result []*AccountNode
+	var walk func(n *AccountNode)
+	walk = func(n *AccountNode) {
+		for _, c := range n.Children {
+			result = append(result, c)
+			walk(c)
+		}
+	}
+	walk(n)
+	return result
+}
+
+// Siblings returns the other accounts having the account's parent, or the
+// other roots for a root.
+func (t *AccountTree) Siblings(id string) []*AccountNode {
+	n := t.nodes[id]
+	if n == nil {
+		return nil
+	}
+	all := t.roots
+	if n.parent != nil {
+		all = n.parent.Children
+	}
+	var result []*AccountNode
+	for _, s := range all {
+		if s != n {
+			result = append(result, s)
+		}
+	}
+	return result
+}
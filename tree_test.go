@@ -0,0 +1,52 @@
+package coa
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccountTree(t *testing.T) {
+	r := NewCoaRepository(store{})
+	coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+	check(t, err)
+	ids := map[string]string{}
+	for _, n := range []string{"2", "1", "1.2", "1.1", "1.1.1", "1.1.2", "2.1", "3"} {
+		parent := ""
+		if i := strings.LastIndex(n, "."); i != -1 {
+			parent = ids[n[:i]]
+		}
+		a, err := r.SaveAccount(coa.Id, &Account{Number: n, Name: n, Parent: parent, Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		ids[n] = a.Id
+	}
+	check(t, r.DeleteAccount(coa.Id, ids["3"]))
+	tree, err := r.AccountTree(coa.Id)
+	check(t, err)
+	nodeNumbers := func(nodes []*AccountNode) string {
+		ss := make([]string, len(nodes))
+		for i, n := range nodes {
+			ss[i] = n.Number
+		}
+		return strings.Join(ss, " ")
+	}
+	for _, c := range []struct{ name, actual, expected string }{
+		{"roots", nodeNumbers(tree.Roots()), "1 2"},
+		{"children", nodeNumbers(tree.Node(ids["1"]).Children), "1.1 1.2"},
+		{"ancestors", nodeNumbers(tree.Ancestors(ids["1.1.2"])), "1.1 1"},
+		{"descendants", nodeNumbers(tree.Descendants(ids["1"])), "1.1 1.1.1 1.1.2 1.2"},
+		{"siblings", nodeNumbers(tree.Siblings(ids["1.1.1"])), "1.1.2"},
+		{"root siblings", nodeNumbers(tree.Siblings(ids["2"])), "1"},
+		{"removed", nodeNumbers(tree.Descendants(ids["3"])), ""},
+	} {
+		if c.actual != c.expected {
+			t.Errorf("Expected %v %v but was %v", c.name, c.expected, c.actual)
+		}
+	}
+	n := tree.Node(ids["1.1.2"])
+	if n.Depth != 2 || strings.Join(n.Path, " ") != ids["1"]+" "+ids["1.1"]+" "+ids["1.1.2"] {
+		t.Errorf("Expected depth 2 and path 1 1.1 1.1.2 but was %v and %v", n.Depth, n.Path)
+	}
+	if tree.Node(ids["1"]).Depth != 0 || len(tree.Node(ids["1"]).Path) != 1 {
+		t.Errorf("Expected a root at depth 0")
+	}
+}
@@ -0,0 +1,124 @@
+package coa
+
+import (
+	"fmt"
+)
+
+// TransactionalKeyValueStore is implemented by stores able to apply several
+// writes atomically. Update runs fn against a view of the store whose writes
+// become visible only if fn returns nil.
+type TransactionalKeyValueStore interface {
+	KeyValueStore
+	Update(fn func(tx KeyValueStore) error) error
+}
+
+// atomically runs fn with a repository bound to a transaction when the store
+// supports them. Plain stores get a journal instead: the previous value of
+// every key written is kept and put back, best effort, if fn fails.
+func (r *CoaRepository) atomically(fn func(r *CoaRepository) error) error {
+	if r.cache == nil {
+		return r.transaction(fn)
+	}
+	written := map[string]Accounts{}
+	err := r.transaction(func(tx *CoaRepository) error {
+		tx.written = written
+		return fn(tx)
+	})
+	for coaid, accounts := range written {
+		if err == nil {
+			r.cache.add(coaid, accounts)
+		} else {
+			r.cache.remove(coaid)
+		}
+	}
+	return err
+}
+
+func (r *CoaRepository) transaction(fn func(r *CoaRepository) error) error {
+	if ts, ok := r.store.(TransactionalKeyValueStore); ok {
+		return ts.Update(func(tx KeyValueStore) error {
+			return fn(r.withStore(tx))
+		})
+	}
+	j := &journal{KeyValueStore: r.store, saved: map[string][]byte{}}
+	var store KeyValueStore = j
+	if _, ok := r.store.(ScannableKeyValueStore); ok {
+		store = scanJournal{j}
+	}
+	err := fn(r.withStore(store))
+	if err != nil {
+		if rerr := j.rollback(); rerr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *CoaRepository) withStore(store KeyValueStore) *CoaRepository {
+	rr := *r
+	rr.store = store
+	return &rr
+}
+
+type journal struct {
+	KeyValueStore
+	saved map[string][]byte
+	keys  []string
+}
+
+func (j *journal) Put(key []byte, value []byte) error {
+	if err := j.save(key); err != nil {
+		return err
+	}
+	return j.KeyValueStore.Put(key, value)
+}
+
+func (j *journal) save(key []byte) error {
+	k := string(key)
+	if _, ok := j.saved[k]; ok {
+		return nil
+	}
+	old, err := j.KeyValueStore.Get(key)
+	if err != nil {
+		return err
+	}
+	j.saved[k] = old
+	j.keys = append(j.keys, k)
+	return nil
+}
+
+func (j *journal) rollback() error {
+	var result error
+	for i := len(j.keys) - 1; i >= 0; i-- {
+		key, old := []byte(j.keys[i]), j.saved[j.keys[i]]
+		var err error
+		if s, ok := j.KeyValueStore.(ScannableKeyValueStore); ok && len(old) == 0 {
+			err = s.Delete(key)
+		} else {
+			if old == nil {
+				old = []byte{}
+			}
+			err = j.KeyValueStore.Put(key, old)
+		}
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+type scanJournal struct {
+	*journal
+}
+
+func (j scanJournal) Scan(prefix []byte, fn func(key []byte, value []byte) error) error {
+	return j.KeyValueStore.(ScannableKeyValueStore).Scan(prefix, fn)
+}
+
+func (j scanJournal) Delete(key []byte) error {
+	if err := j.save(key); err != nil {
+		return err
+	}
+	return j.KeyValueStore.(ScannableKeyValueStore).Delete(key)
+}
@@ -0,0 +1,101 @@
+package coa
+
+import (
+	"errors"
+	"testing"
+)
+
+var errPutFailed = errors.New("put failed")
+
+// failingStore fails the nth Put, counting from 1.
+type failingStore struct {
+	store
+	n, puts int
+}
+
+func (s *failingStore) Put(key []byte, value []byte) error {
+	s.puts++
+	if s.puts == s.n {
+		return errPutFailed
+	}
+	return s.store.Put(key, value)
+}
+
+// txStore stages writes and applies them only when Update succeeds.
+type txStore struct {
+	*failingStore
+}
+
+type txView struct {
+	*failingStore
+	staged store
+}
+
+func (s txStore) Update(fn func(tx KeyValueStore) error) error {
+	tx := &txView{s.failingStore, store{}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for k, v := range tx.staged {
+		s.store[k] = v
+	}
+	return nil
+}
+
+func (tx *txView) Get(key []byte) ([]byte, error) {
+	if _, ok := tx.staged[string(key)]; ok {
+		return tx.staged.Get(key)
+	}
+	return tx.failingStore.Get(key)
+}
+
+func (tx *txView) Put(key []byte, value []byte) error {
+	tx.puts++
+	if tx.puts == tx.n {
+		return errPutFailed
+	}
+	return tx.staged.Put(key, value)
+}
+
+func TestSaveAccountIsAtomic(t *testing.T) {
+	plain := func(s *failingStore) KeyValueStore { return s }
+	transactional := func(s *failingStore) KeyValueStore { return txStore{s} }
+	for name, test := range map[string]struct {
+		store   func(*failingStore) KeyValueStore
+		options []Option
+	}{
+		"plain":                {plain, nil},
+		"transactional":        {transactional, nil},
+		"cached plain":         {plain, []Option{WithCache(100)}},
+		"cached transactional": {transactional, []Option{WithCache(100)}},
+	} {
+		fs := &failingStore{store: store{}}
+		r := NewCoaRepository(test.store(fs), test.options...)
+		coa, err := r.SaveChartOfAccounts(&ChartOfAccounts{Name: "coa"})
+		check(t, err)
+		a1, err := r.SaveAccount(coa.Id, &Account{Number: "1", Name: "a1", Tags: []string{"balanceSheet", "increaseOnDebit"}})
+		check(t, err)
+		// the second put is the one turning the parent into a summary account
+		fs.n, fs.puts = 2, 0
+		a11 := &Account{Number: "11", Name: "a11", Parent: a1.Id, Tags: []string{"balanceSheet", "increaseOnDebit"}}
+		if _, err := r.SaveAccount(coa.Id, a11); err != errPutFailed {
+			t.Errorf("%v: Expected errPutFailed but was %v", name, err)
+		}
+		if a11.Id != "" {
+			t.Errorf("%v: Expected the argument to be left untouched but Id was %v", name, a11.Id)
+		}
+		accounts, err := r.AllAccounts(coa.Id)
+		check(t, err)
+		if len(accounts) != 1 || !accounts[0].Tags.Contains("detail") || accounts[0].Tags.Contains("summary") {
+			t.Errorf("%v: Expected only a detail a1 but was %v", name, accounts)
+		}
+		fs.n = 0
+		_, err = r.SaveAccount(coa.Id, a11)
+		check(t, err)
+		accounts, err = r.AllAccounts(coa.Id)
+		check(t, err)
+		if len(accounts) != 2 || !accounts[0].Tags.Contains("summary") {
+			t.Errorf("%v: Expected a summary a1 and a11 but was %v", name, accounts)
+		}
+	}
+}